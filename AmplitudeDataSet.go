@@ -1,47 +1,37 @@
 package rti
 
-import (
-	"encoding/binary"
-	"math"
-)
-
 // AmplitudeDataSet will contain all the Amplitude Data set values.
 // These values describe water profile signal strength in dB.
 // The data will be stored in array.  The array size will be based off the
 // base data set.
 // Bin x Beam
 type AmplitudeDataSet struct {
-	Base      BaseDataSet // Base Dataset
-	Amplitude [][]float32 // Amplitude data in dB
+	Base      BaseDataSet            // Base Dataset
+	Amplitude BinBeamMatrix[float32] // Amplitude data in dB
 }
 
 // Decode will take the binary data and decode into
 // into the ensemble data set.
 func (amp *AmplitudeDataSet) Decode(data []byte) {
 
-	// Initialize the 2D array
-	// [Bins][Beams]
-	amp.Amplitude = make([][]float32, amp.Base.NumElements)
-	for i := range amp.Amplitude {
-		amp.Amplitude[i] = make([]float32, amp.Base.ElementMultiplier)
-	}
+	// Decode the [Bins][Beams] data
+	amp.Amplitude = decodeBinBeamMatrix[float32](&amp.Base, data)
 
-	// Not enough data
-	if uint32(len(data)) < amp.Base.NumElements*amp.Base.ElementMultiplier*uint32(BytesInFloat) {
-		return
+	// Convert the bad values to NaN
+	if amp.Base.badToNaN {
+		badToNaN(amp.Amplitude)
 	}
 
-	// Set each beam and bin data
-	ptr := 0
-	for beam := 0; beam < int(amp.Base.ElementMultiplier); beam++ {
-		for bin := 0; bin < int(amp.Base.NumElements); bin++ {
-			// Get the location of the data
-			ptr = GetBinBeamIndex(int(amp.Base.NameLen), int(amp.Base.NumElements), beam, bin)
+}
 
-			// Set the data to float
-			bits := binary.LittleEndian.Uint32(data[ptr : ptr+BytesInFloat])
-			amp.Amplitude[bin][beam] = math.Float32frombits(bits)
-		}
-	}
+// IsVerticalBeam will check if the dataset contains a single vertical beam.
+// A vertical beam subsystem sends its bin data with an element multiplier of 1.
+func (amp *AmplitudeDataSet) IsVerticalBeam() bool {
+	return amp.Base.ElementMultiplier == 1
+}
 
+// GoodMask will create a mask of the good values.
+// The mask is true for each good value and false for each bad value.
+func (amp *AmplitudeDataSet) GoodMask() BinBeamMatrix[bool] {
+	return GoodMask(amp.Amplitude)
 }
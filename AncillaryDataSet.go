@@ -16,8 +16,8 @@ type AncillaryDataSet struct {
 	Heading         float32     // Heading in degrees
 	Pitch           float32     // Pitch in degrees
 	Roll            float32     // Roll in degrees
-	WaterTemp       float32     // Water temperature in degrees farenheit
-	SystemTemp      float32     // System temperature in degrees farenheit
+	WaterTemp       float32     // Water temperature in degrees Celsius
+	SystemTemp      float32     // System temperature in degrees Celsius
 	Salinity        float32     // Salinity in Parts per Thousand (PPT)
 	Pressure        float32     // Pressure in Pascals
 	TransducerDepth float32     // Depth of the transducer in water in meters.  Used for speed of sound.
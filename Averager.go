@@ -0,0 +1,496 @@
+package rti
+
+import (
+	"math"
+	"time"
+)
+
+// AverageMode is how the ensembles are grouped into an average.
+type AverageMode int
+
+// Average modes
+const (
+	AverageByCount          AverageMode = iota // Average every Count ensembles
+	AverageByTime                              // Average the ensembles in each Interval of time
+	AverageByEnsembleNumber                    // Average the ensembles in each block of Count ensemble numbers
+)
+
+// AverageInfo describes the ensembles used in an averaged ensemble.
+type AverageInfo struct {
+	NumEnsembles int                             // Number of ensembles averaged
+	Samples      map[string]BinBeamMatrix[int32] // Number of good values averaged in each bin and beam by dataset ID
+}
+
+// Averager will accumulate ensembles and create an averaged ensemble
+// when each window is complete.  The window is set by the mode.
+//
+// Velocities are averaged as vectors by averaging each component.  Amplitude
+// is averaged in linear power and converted back to dB.  Heading is averaged
+// as a circular value.  Good ping counts are summed.  Bad values are excluded,
+// and the number of values used in each bin is kept in the AverageInfo.
+type Averager struct {
+	Mode     AverageMode   // How the ensembles are grouped
+	Count    int           // Number of ensembles or ensemble numbers in each average
+	Interval time.Duration // Time window of each average
+
+	window []Ensemble // Ensembles in the current window
+	key    int64      // Key of the current window
+}
+
+// Add will add the ensemble to the current window.  If the ensemble starts a new
+// window or completes the current window, the averaged ensemble is returned.
+func (avg *Averager) Add(ens Ensemble) (Ensemble, bool) {
+	var result Ensemble
+	var done bool
+
+	switch avg.Mode {
+	case AverageByTime, AverageByEnsembleNumber:
+		key, ok := avg.windowKey(&ens)
+		if ok && len(avg.window) > 0 && key != avg.key {
+			result, done = avg.Flush()
+		}
+		if ok {
+			avg.key = key
+		}
+		avg.window = append(avg.window, ens)
+	default:
+		avg.window = append(avg.window, ens)
+		if len(avg.window) >= avg.Count {
+			result, done = avg.Flush()
+		}
+	}
+
+	return result, done
+}
+
+// Flush will average the ensembles in the current window and start a new window.
+// If there are no ensembles in the window, false is returned.
+func (avg *Averager) Flush() (Ensemble, bool) {
+	if len(avg.window) == 0 {
+		return Ensemble{}, false
+	}
+
+	result := AverageEnsembles(avg.window)
+	avg.window = nil
+
+	return result, true
+}
+
+// windowKey will get the key of the window for the ensemble.
+func (avg *Averager) windowKey(ens *Ensemble) (int64, bool) {
+	if avg.Mode == AverageByEnsembleNumber {
+		if avg.Count <= 0 {
+			return 0, false
+		}
+		return int64(ens.GetEnsembleData().EnsembleNumber) / int64(avg.Count), true
+	}
+
+	t, err := ens.GetEnsembleData().Time()
+	if err != nil || avg.Interval <= 0 {
+		return 0, false
+	}
+
+	return t.Truncate(avg.Interval).UnixNano(), true
+}
+
+// AverageEnsembles will average all the ensembles into a single ensemble.
+// The Ensemble Data Set is from the first ensemble with the ping counts summed.
+// The NMEA Data Set is from the last ensemble with a GPS position.
+func AverageEnsembles(ensembles []Ensemble) Ensemble {
+	var result Ensemble
+	if len(ensembles) == 0 {
+		return result
+	}
+
+	first := &ensembles[0]
+	info := &AverageInfo{NumEnsembles: len(ensembles), Samples: map[string]BinBeamMatrix[int32]{}}
+	result.Average = info
+
+	// Ensemble Data Set
+	result.EnsembleData = *first.GetEnsembleData()
+	result.EnsembleData.Base.raw = nil
+	result.EnsembleData.DesiredPingCount = 0
+	result.EnsembleData.ActualPingCount = 0
+	for i := range ensembles {
+		ensData := ensembles[i].GetEnsembleData()
+		result.EnsembleData.DesiredPingCount += ensData.DesiredPingCount
+		result.EnsembleData.ActualPingCount += ensData.ActualPingCount
+	}
+
+	// Ancillary Data Set
+	result.AncillaryData = averageAncillary(ensembles)
+
+	// Bin datasets
+	var beamVel, instVel, earthVel, amp, corr []BinBeamMatrix[float32]
+	var goodBeam, goodEarth []BinBeamMatrix[int32]
+	for i := range ensembles {
+		ens := &ensembles[i]
+		beamVel = append(beamVel, ens.GetBeamVelocityData().Velocity)
+		instVel = append(instVel, ens.GetInstrumentVelocityData().Velocity)
+		earthVel = append(earthVel, ens.GetEarthVelocityData().Velocity)
+		amp = append(amp, ens.GetAmplitudeData().Amplitude)
+		corr = append(corr, ens.GetCorrelationData().Correlation)
+		goodBeam = append(goodBeam, ens.GetGoodBeamData().GoodPings)
+		goodEarth = append(goodEarth, ens.GetGoodEarthData().GoodPings)
+	}
+
+	result.BeamVelocityData.Base = averageBase(first.BeamVelocityData.Base)
+	result.BeamVelocityData.Velocity = averageMatrix(beamVel, false, info, beamVelocityID, &result.BeamVelocityData.Base)
+
+	result.InstrumentVelocityData.Base = averageBase(first.InstrumentVelocityData.Base)
+	result.InstrumentVelocityData.Velocity = averageMatrix(instVel, false, info, instrumentVelocityID, &result.InstrumentVelocityData.Base)
+
+	result.EarthVelocityData.Base = averageBase(first.EarthVelocityData.Base)
+	result.EarthVelocityData.Velocity = averageMatrix(earthVel, false, info, earthVelocityID, &result.EarthVelocityData.Base)
+	result.EarthVelocityData.Declination = first.EarthVelocityData.Declination
+	result.EarthVelocityData.DeclinationFromModel = first.EarthVelocityData.DeclinationFromModel
+	result.EarthVelocityData.HeadingOffset = first.EarthVelocityData.HeadingOffset
+	result.EarthVelocityData.Reference = first.EarthVelocityData.Reference
+	result.EarthVelocityData.calcVectors()
+
+	result.AmplitudeData.Base = averageBase(first.AmplitudeData.Base)
+	result.AmplitudeData.Amplitude = averageMatrix(amp, true, info, amplitudeID, &result.AmplitudeData.Base)
+
+	result.CorrelationData.Base = averageBase(first.CorrelationData.Base)
+	result.CorrelationData.Correlation = averageMatrix(corr, false, info, correlationID, &result.CorrelationData.Base)
+
+	result.GoodBeamData.Base = averageBase(first.GoodBeamData.Base)
+	result.GoodBeamData.GoodPings = sumMatrix(goodBeam)
+
+	result.GoodEarthData.Base = averageBase(first.GoodEarthData.Base)
+	result.GoodEarthData.GoodPings = sumMatrix(goodEarth)
+
+	// Bottom Track Data Set
+	result.BottomTrackData = averageBottomTrack(ensembles)
+
+	// NMEA Data Set
+	for i := range ensembles {
+		if nmea := ensembles[i].GetNmeaData(); nmea.HasPosition || i == 0 {
+			result.NmeaData = *nmea
+			result.NmeaData.Base.raw = nil
+		}
+	}
+
+	// Vertical beam
+	result.VerticalBeamData = averageVerticalBeam(ensembles)
+
+	return result
+}
+
+// averageBase will copy the base dataset for the averaged dataset.
+func averageBase(base BaseDataSet) BaseDataSet {
+	base.raw = nil
+	return base
+}
+
+// averageMatrix will average the good values of each bin and beam.  Matrices with
+// a different size than the first matrix with data are not used.  If dB is set,
+// the values are averaged as power.  The number of values used is added to the info.
+func averageMatrix(mats []BinBeamMatrix[float32], dB bool, info *AverageInfo, id string, base *BaseDataSet) BinBeamMatrix[float32] {
+	numBins, numBeams := matrixSize(mats)
+	sum := make([]float64, numBins*numBeams)
+	counts := NewBinBeamMatrix[int32](numBins, numBeams)
+
+	for _, mat := range mats {
+		if mat.NumBins != numBins || mat.NumBeams != numBeams {
+			continue
+		}
+
+		for i, v := range mat.Data {
+			if IsBad(v) {
+				continue
+			}
+
+			if dB {
+				sum[i] += math.Pow(10, float64(v)/10.0)
+			} else {
+				sum[i] += float64(v)
+			}
+			counts.Data[i]++
+		}
+	}
+
+	bad := base.badValue()
+	result := NewBinBeamMatrix[float32](numBins, numBeams)
+	for i := range result.Data {
+		switch {
+		case counts.Data[i] == 0:
+			result.Data[i] = bad
+		case dB:
+			result.Data[i] = float32(10.0 * math.Log10(sum[i]/float64(counts.Data[i])))
+		default:
+			result.Data[i] = float32(sum[i] / float64(counts.Data[i]))
+		}
+	}
+
+	if numBins > 0 {
+		info.Samples[id] = counts
+	}
+
+	return result
+}
+
+// sumMatrix will sum the values of each bin and beam.  Matrices with a different
+// size than the first matrix with data are not used.
+func sumMatrix(mats []BinBeamMatrix[int32]) BinBeamMatrix[int32] {
+	numBins, numBeams := matrixSize(mats)
+	result := NewBinBeamMatrix[int32](numBins, numBeams)
+
+	for _, mat := range mats {
+		if mat.NumBins != numBins || mat.NumBeams != numBeams {
+			continue
+		}
+
+		for i, v := range mat.Data {
+			result.Data[i] += v
+		}
+	}
+
+	return result
+}
+
+// matrixSize will get the size of the first matrix with data.
+func matrixSize[T any](mats []BinBeamMatrix[T]) (int, int) {
+	for _, mat := range mats {
+		if len(mat.Data) > 0 {
+			return mat.NumBins, mat.NumBeams
+		}
+	}
+
+	return 0, 0
+}
+
+// averageAncillary will average the Ancillary Data Sets.
+// The first ping time is from the first ensemble and the last ping time
+// is the last ping of the last ensemble relative to the first ensemble time.
+func averageAncillary(ensembles []Ensemble) AncillaryDataSet {
+	first := ensembles[0].GetAncillaryData()
+	last := ensembles[len(ensembles)-1].GetAncillaryData()
+
+	var anc AncillaryDataSet
+	anc.Base = averageBase(first.Base)
+
+	values := func(get func(a *AncillaryDataSet) float32) []float32 {
+		vals := make([]float32, len(ensembles))
+		for i := range ensembles {
+			vals[i] = get(ensembles[i].GetAncillaryData())
+		}
+		return vals
+	}
+
+	anc.FirstBinRange = meanValue(values(func(a *AncillaryDataSet) float32 { return a.FirstBinRange }))
+	anc.BinSize = meanValue(values(func(a *AncillaryDataSet) float32 { return a.BinSize }))
+	anc.Heading = circularMean(values(func(a *AncillaryDataSet) float32 { return a.Heading }))
+	anc.Pitch = meanValue(values(func(a *AncillaryDataSet) float32 { return a.Pitch }))
+	anc.Roll = signedAngle(circularMean(values(func(a *AncillaryDataSet) float32 { return a.Roll })))
+	anc.WaterTemp = meanValue(values(func(a *AncillaryDataSet) float32 { return a.WaterTemp }))
+	anc.SystemTemp = meanValue(values(func(a *AncillaryDataSet) float32 { return a.SystemTemp }))
+	anc.Salinity = meanValue(values(func(a *AncillaryDataSet) float32 { return a.Salinity }))
+	anc.Pressure = meanValue(values(func(a *AncillaryDataSet) float32 { return a.Pressure }))
+	anc.TransducerDepth = meanValue(values(func(a *AncillaryDataSet) float32 { return a.TransducerDepth }))
+	anc.SpeedOfSound = meanValue(values(func(a *AncillaryDataSet) float32 { return a.SpeedOfSound }))
+
+	// Ping times
+	anc.FirstPingTime = first.FirstPingTime
+	anc.LastPingTime = last.LastPingTime
+	t0, err0 := ensembles[0].GetEnsembleData().Time()
+	t1, err1 := ensembles[len(ensembles)-1].GetEnsembleData().Time()
+	if err0 == nil && err1 == nil {
+		anc.LastPingTime += float32(t1.Sub(t0).Seconds())
+	}
+
+	return anc
+}
+
+// averageBottomTrack will average the Bottom Track Data Sets.
+// The beam values are averaged for each beam and the ping counts are summed.
+func averageBottomTrack(ensembles []Ensemble) BottomTrackDataSet {
+	first := ensembles[0].GetBottomTrackData()
+
+	var bt BottomTrackDataSet
+	bt.Base = averageBase(first.Base)
+	bt.NumBeams = first.NumBeams
+	bt.Status = first.Status
+	bt.FirstPingTime = first.FirstPingTime
+	bt.LastPingTime = ensembles[len(ensembles)-1].GetBottomTrackData().LastPingTime
+
+	var all []*BottomTrackDataSet
+	for i := range ensembles {
+		all = append(all, ensembles[i].GetBottomTrackData())
+	}
+
+	values := func(get func(b *BottomTrackDataSet) float32) []float32 {
+		vals := make([]float32, len(all))
+		for i, b := range all {
+			vals[i] = get(b)
+		}
+		return vals
+	}
+	beams := func(get func(b *BottomTrackDataSet) []float32, dB bool) []float32 {
+		var slices [][]float32
+		for _, b := range all {
+			slices = append(slices, get(b))
+		}
+		return averageSlices(slices, bt.NumBeams, dB, &bt.Base)
+	}
+
+	bt.Heading = circularMean(values(func(b *BottomTrackDataSet) float32 { return b.Heading }))
+	bt.Pitch = meanValue(values(func(b *BottomTrackDataSet) float32 { return b.Pitch }))
+	bt.Roll = signedAngle(circularMean(values(func(b *BottomTrackDataSet) float32 { return b.Roll })))
+	bt.WaterTemp = meanValue(values(func(b *BottomTrackDataSet) float32 { return b.WaterTemp }))
+	bt.SystemTemp = meanValue(values(func(b *BottomTrackDataSet) float32 { return b.SystemTemp }))
+	bt.Salinity = meanValue(values(func(b *BottomTrackDataSet) float32 { return b.Salinity }))
+	bt.Pressure = meanValue(values(func(b *BottomTrackDataSet) float32 { return b.Pressure }))
+	bt.TransducerDepth = meanValue(values(func(b *BottomTrackDataSet) float32 { return b.TransducerDepth }))
+	bt.SpeedOfSound = meanValue(values(func(b *BottomTrackDataSet) float32 { return b.SpeedOfSound }))
+	for _, b := range all {
+		bt.ActualPingCount += b.ActualPingCount
+	}
+
+	if bt.NumBeams == 0 {
+		return bt
+	}
+
+	bt.Range = beams(func(b *BottomTrackDataSet) []float32 { return b.Range }, false)
+	bt.SNR = beams(func(b *BottomTrackDataSet) []float32 { return b.SNR }, false)
+	bt.Amplitude = beams(func(b *BottomTrackDataSet) []float32 { return b.Amplitude }, true)
+	bt.Correlation = beams(func(b *BottomTrackDataSet) []float32 { return b.Correlation }, false)
+	bt.BeamVelocity = beams(func(b *BottomTrackDataSet) []float32 { return b.BeamVelocity }, false)
+	bt.InstrumentVelocity = beams(func(b *BottomTrackDataSet) []float32 { return b.InstrumentVelocity }, false)
+	bt.EarthVelocity = beams(func(b *BottomTrackDataSet) []float32 { return b.EarthVelocity }, false)
+	bt.BeamGood = sumSlices(all, bt.NumBeams, func(b *BottomTrackDataSet) []float32 { return b.BeamGood })
+	bt.InstrumentGood = sumSlices(all, bt.NumBeams, func(b *BottomTrackDataSet) []float32 { return b.InstrumentGood })
+	bt.EarthGood = sumSlices(all, bt.NumBeams, func(b *BottomTrackDataSet) []float32 { return b.EarthGood })
+
+	return bt
+}
+
+// averageVerticalBeam will average the merged vertical beams.
+// If the first ensemble has no vertical beam, nil is returned.
+func averageVerticalBeam(ensembles []Ensemble) *VerticalBeam {
+	first := ensembles[0].VerticalBeamData
+	if first == nil {
+		return nil
+	}
+
+	// The vertical beam is created from the bin datasets, so it has the same bad value
+	base := &ensembles[0].BeamVelocityData.Base
+
+	var vel, amp, corr [][]float32
+	for i := range ensembles {
+		if vb := ensembles[i].VerticalBeamData; vb != nil {
+			vel = append(vel, vb.Velocity)
+			amp = append(amp, vb.Amplitude)
+			corr = append(corr, vb.Correlation)
+		}
+	}
+
+	return &VerticalBeam{
+		Subsystem:     first.Subsystem,
+		FirstBinRange: first.FirstBinRange,
+		BinSize:       first.BinSize,
+		Velocity:      averageSlices(vel, len(first.Velocity), false, base),
+		Amplitude:     averageSlices(amp, len(first.Amplitude), true, base),
+		Correlation:   averageSlices(corr, len(first.Correlation), false, base),
+	}
+}
+
+// averageSlices will average the good values at each index of the slices.
+// Slices with a different length are not used.  If dB is set, the values
+// are averaged as power.
+func averageSlices(slices [][]float32, length int, dB bool, base *BaseDataSet) []float32 {
+	mats := make([]BinBeamMatrix[float32], 0, len(slices))
+	for _, s := range slices {
+		mats = append(mats, BinBeamMatrix[float32]{NumBins: len(s), NumBeams: 1, Data: s})
+	}
+
+	info := &AverageInfo{Samples: map[string]BinBeamMatrix[int32]{}}
+	avg := averageMatrix(mats, dB, info, "", base)
+	if avg.NumBins != length {
+		return nil
+	}
+
+	return avg.Data
+}
+
+// sumSlices will sum the values at each index of the slices.
+func sumSlices(all []*BottomTrackDataSet, length int, get func(b *BottomTrackDataSet) []float32) []float32 {
+	sum := make([]float32, length)
+	for _, b := range all {
+		values := get(b)
+		if len(values) != length {
+			continue
+		}
+		for i, v := range values {
+			sum[i] += v
+		}
+	}
+
+	return sum
+}
+
+// meanValue will average the good values.
+// If there are no good values, the bad value of the values is returned.
+func meanValue(values []float32) float32 {
+	var sum float64
+	var count int
+	bad := float32(BadVelocity)
+	for _, v := range values {
+		if IsBad(v) {
+			bad = badLike(v)
+		} else {
+			sum += float64(v)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return bad
+	}
+
+	return float32(sum / float64(count))
+}
+
+// circularMean will average the good angles in degrees.
+// The result is from 0 to 360 degrees.
+// If there are no good values, the bad value of the values is returned.
+func circularMean(values []float32) float32 {
+	var sumSin, sumCos float64
+	var count int
+	bad := float32(BadVelocity)
+	for _, v := range values {
+		if IsBad(v) {
+			bad = badLike(v)
+			continue
+		}
+
+		sin, cos := math.Sincos(float64(v) * (math.Pi / 180.0))
+		sumSin += sin
+		sumCos += cos
+		count++
+	}
+
+	if count == 0 {
+		return bad
+	}
+
+	mean := float32(math.Atan2(sumSin, sumCos) * (180.0 / math.Pi))
+	if mean < 0 {
+		mean += 360.0
+	}
+	if mean >= 360.0 {
+		mean -= 360.0
+	}
+
+	return mean
+}
+
+// signedAngle will wrap the good angle in degrees to -180 to 180 degrees.
+// The roll of an upward looking ADCP is near +/- 180 degrees.
+func signedAngle(angle float32) float32 {
+	if !IsBad(angle) && angle > 180.0 {
+		angle -= 360.0
+	}
+
+	return angle
+}
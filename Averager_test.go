@@ -0,0 +1,128 @@
+package rti
+
+import (
+	"math"
+	"testing"
+)
+
+// TestCircularMean will check the angles are averaged across 0 and 360 degrees.
+func TestCircularMean(t *testing.T) {
+	tests := []struct {
+		values []float32
+		want   float32
+	}{
+		{[]float32{350, 10}, 0},
+		{[]float32{359, 1, 3}, 1},
+		{[]float32{10, 20, 30}, 20},
+		{[]float32{170, -170}, 180},
+		{[]float32{270, 300}, 285},
+		{[]float32{350, BadVelocity, 10}, 0},
+		{[]float32{BadVelocity, BadVelocity}, BadVelocity},
+	}
+
+	for _, test := range tests {
+		got := circularMean(test.values)
+		if d := math.Mod(float64(got-test.want)+540, 360) - 180; math.Abs(d) > 1e-3 {
+			t.Errorf("circularMean(%v) = %g, want %g", test.values, got, test.want)
+		}
+	}
+
+	if got := circularMean([]float32{float32(math.NaN())}); !math.IsNaN(float64(got)) {
+		t.Errorf("circularMean(NaN) = %g, want NaN", got)
+	}
+}
+
+// TestRollMean will check the roll of an upward looking ADCP stays near +/- 180 degrees.
+func TestRollMean(t *testing.T) {
+	tests := []struct {
+		values []float32
+		want   float32
+	}{
+		{[]float32{-2, 4}, 1},
+		{[]float32{179, -179}, 180},
+		{[]float32{178, -176}, -179},
+		{[]float32{-178, -176}, -177},
+	}
+
+	for _, test := range tests {
+		if got := signedAngle(circularMean(test.values)); math.Abs(float64(got-test.want)) > 1e-3 {
+			t.Errorf("roll mean of %v = %g, want %g", test.values, got, test.want)
+		}
+	}
+}
+
+// TestAverageMatrix will check the mean, the power average of dB values and the
+// number of good samples in each bin.
+func TestAverageMatrix(t *testing.T) {
+	tests := []struct {
+		values []float32
+		dB     bool
+		want   float32
+		count  int32
+	}{
+		{[]float32{1, 2, 6}, false, 3, 3},
+		{[]float32{1, BadVelocity, 3}, false, 2, 2},
+		{[]float32{10, 20}, true, 17.4036, 2},     // 10 log10((10 + 100) / 2)
+		{[]float32{30, 30, 30}, true, 30, 3},      // Same value
+		{[]float32{40, BadVelocity}, true, 40, 1}, // Bad value not used
+		{[]float32{BadVelocity, BadVelocity}, false, BadVelocity, 0},
+	}
+
+	for _, test := range tests {
+		var mats []BinBeamMatrix[float32]
+		for _, v := range test.values {
+			mat := NewBinBeamMatrix[float32](1, 1)
+			mat.Set(0, 0, v)
+			mats = append(mats, mat)
+		}
+
+		info := &AverageInfo{Samples: map[string]BinBeamMatrix[int32]{}}
+		got := averageMatrix(mats, test.dB, info, amplitudeID, &BaseDataSet{})
+		if math.Abs(float64(got.At(0, 0)-test.want)) > 1e-3 {
+			t.Errorf("averageMatrix(%v, dB %v) = %g, want %g", test.values, test.dB, got.At(0, 0), test.want)
+		}
+		if count := info.Samples[amplitudeID].At(0, 0); count != test.count {
+			t.Errorf("averageMatrix(%v) samples = %d, want %d", test.values, count, test.count)
+		}
+	}
+}
+
+// TestAveragerByCount will check an average is made for every Count ensembles and
+// the heading and roll are averaged as angles.
+func TestAveragerByCount(t *testing.T) {
+	avg := Averager{Mode: AverageByCount, Count: 2}
+	headings := []float32{350, 10, 90}
+	rolls := []float32{179, -179, 0}
+
+	var results []Ensemble
+	for i := range headings {
+		var ens Ensemble
+		ens.EnsembleData = EnsembleDataSet{EnsembleNumber: uint32(i + 1), Year: 2024, Month: 1, Day: 1, Second: uint32(i), ActualPingCount: 10}
+		ens.AncillaryData.Heading = headings[i]
+		ens.AncillaryData.Roll = rolls[i]
+		if result, ok := avg.Add(ens); ok {
+			results = append(results, result)
+		}
+	}
+	if result, ok := avg.Flush(); ok {
+		results = append(results, result)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("%d averages, want 2", len(results))
+	}
+
+	first := results[0]
+	if first.Average.NumEnsembles != 2 || first.EnsembleData.ActualPingCount != 20 {
+		t.Errorf("first average has %d ensembles and %d pings, want 2 and 20", first.Average.NumEnsembles, first.EnsembleData.ActualPingCount)
+	}
+	if h := first.AncillaryData.Heading; math.Abs(math.Mod(float64(h)+180, 360)-180) > 1e-3 {
+		t.Errorf("first average heading = %g, want 0", h)
+	}
+	if r := first.AncillaryData.Roll; math.Abs(float64(r)-180) > 1e-3 {
+		t.Errorf("first average roll = %g, want 180", r)
+	}
+	if results[1].Average.NumEnsembles != 1 || results[1].AncillaryData.Heading != 90 {
+		t.Errorf("second average has %d ensembles and heading %g, want 1 and 90", results[1].Average.NumEnsembles, results[1].AncillaryData.Heading)
+	}
+}
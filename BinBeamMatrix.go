@@ -0,0 +1,106 @@
+package rti
+
+// BinBeamMatrix will contain bin and beam data.
+// All the values are stored in a single slice so only one
+// allocation is needed for the dataset.  The values are stored
+// by bin, so all the beams for a bin are next to each other.
+// Bin x Beam
+type BinBeamMatrix[T any] struct {
+	NumBins  int // Number of bins
+	NumBeams int // Number of beams
+	Data     []T // Values stored [Bin*NumBeams + Beam]
+}
+
+// binBeamNumber is the value types that can be decoded into a BinBeamMatrix.
+type binBeamNumber interface {
+	~float32 | ~float64 | ~int32 | ~uint8
+}
+
+// NewBinBeamMatrix will create a matrix with the given number of bins and beams.
+func NewBinBeamMatrix[T any](numBins int, numBeams int) BinBeamMatrix[T] {
+	return BinBeamMatrix[T]{
+		NumBins:  numBins,
+		NumBeams: numBeams,
+		Data:     make([]T, numBins*numBeams),
+	}
+}
+
+// At will get the value for the bin and beam.
+func (mat BinBeamMatrix[T]) At(bin int, beam int) T {
+	return mat.Data[bin*mat.NumBeams+beam]
+}
+
+// Set will set the value for the bin and beam.
+func (mat BinBeamMatrix[T]) Set(bin int, beam int, value T) {
+	mat.Data[bin*mat.NumBeams+beam] = value
+}
+
+// Bin will get all the beam values for the bin.
+// The slice shares the data with the matrix.
+func (mat BinBeamMatrix[T]) Bin(bin int) []T {
+	start := bin * mat.NumBeams
+	return mat.Data[start : start+mat.NumBeams : start+mat.NumBeams]
+}
+
+// Beam will get all the bin values for the beam.
+// The values are copied into a new slice.
+func (mat BinBeamMatrix[T]) Beam(beam int) []T {
+	return mat.Column(beam, make([]T, 0, mat.NumBins))
+}
+
+// Column will append all the bin values for the beam to dst.
+// This can be used to reuse a slice between ensembles.
+func (mat BinBeamMatrix[T]) Column(beam int, dst []T) []T {
+	dst = dst[:0]
+	for bin := 0; bin < mat.NumBins; bin++ {
+		dst = append(dst, mat.Data[bin*mat.NumBeams+beam])
+	}
+
+	return dst
+}
+
+// SubBins will get the bins from first up to but not including last.
+// The matrix returned shares the data with this matrix.
+func (mat BinBeamMatrix[T]) SubBins(first int, last int) BinBeamMatrix[T] {
+	return BinBeamMatrix[T]{
+		NumBins:  last - first,
+		NumBeams: mat.NumBeams,
+		Data:     mat.Data[first*mat.NumBeams : last*mat.NumBeams : last*mat.NumBeams],
+	}
+}
+
+// Map will create a new matrix with the function applied to each value.
+func (mat BinBeamMatrix[T]) Map(fn func(bin int, beam int, value T) T) BinBeamMatrix[T] {
+	result := NewBinBeamMatrix[T](mat.NumBins, mat.NumBeams)
+	for bin := 0; bin < mat.NumBins; bin++ {
+		for beam := 0; beam < mat.NumBeams; beam++ {
+			result.Data[bin*mat.NumBeams+beam] = fn(bin, beam, mat.Data[bin*mat.NumBeams+beam])
+		}
+	}
+
+	return result
+}
+
+// decodeBinBeamMatrix will decode the bin and beam data in the dataset.
+// The number of bins and beams and the data type are based off the base dataset.
+// If there is not enough data, the matrix is returned with all zeros.
+func decodeBinBeamMatrix[T binBeamNumber](base *BaseDataSet, data []byte) BinBeamMatrix[T] {
+
+	// [Bins][Beams]
+	mat := NewBinBeamMatrix[T](int(base.NumElements), int(base.ElementMultiplier))
+
+	// Not enough data
+	if !hasBinBeamData(base, data) {
+		return mat
+	}
+
+	// Set each beam and bin data
+	for beam := 0; beam < mat.NumBeams; beam++ {
+		for bin := 0; bin < mat.NumBins; bin++ {
+			// Decode the value based off the data type
+			mat.Data[bin*mat.NumBeams+beam] = T(getBinBeamValue(data, int(base.NameLen), mat.NumBins, beam, bin, base.Enstype))
+		}
+	}
+
+	return mat
+}
@@ -0,0 +1,114 @@
+package rti
+
+import (
+	"fmt"
+	"math"
+)
+
+// BinRanges will get the vertical range from the transducer to the center
+// of each bin in meters.
+func (ens *Ensemble) BinRanges() []float32 {
+	anc := ens.GetAncillaryData()
+
+	ranges := make([]float32, ens.numBins())
+	for bin := range ranges {
+		ranges[bin] = anc.FirstBinRange + float32(bin)*anc.BinSize
+	}
+
+	return ranges
+}
+
+// BinDepths will get the depth below the surface of the center of each bin
+// in meters.  The bins of a downward looking ADCP are deeper than the transducer
+// and the bins of an upward looking ADCP are shallower than the transducer.
+func (ens *Ensemble) BinDepths() []float32 {
+	anc := ens.GetAncillaryData()
+
+	depths := ens.BinRanges()
+	for bin, r := range depths {
+		if anc.IsUpwardLooking() {
+			depths[bin] = anc.TransducerDepth - r
+		} else {
+			depths[bin] = anc.TransducerDepth + r
+		}
+	}
+
+	return depths
+}
+
+// MapBins will re-assign the beam velocities to the level bins.  When the ADCP
+// is tilted, each beam is more or less vertical than the beam angle, so bin N of
+// each beam is at a different depth.  The sample of each beam nearest to the level
+// depth of the bin is used for the bin.  If the nearest sample is outside the
+// beam, the bin is bad.  The beam angle and beam offset are in degrees.
+func MapBins(beam *BeamVelocityDataSet, anc *AncillaryDataSet, beamAngle float64, beamOffset float64) (BeamVelocityDataSet, error) {
+	dirs, err := beamDirections(beamAngle, beam.Velocity.NumBeams, beamOffset)
+	if err != nil {
+		return BeamVelocityDataSet{}, err
+	}
+
+	mapped := BeamVelocityDataSet{
+		Base:     beam.Base,
+		Velocity: NewBinBeamMatrix[float32](beam.Velocity.NumBins, beam.Velocity.NumBeams),
+	}
+	mapped.Base.raw = nil
+
+	// Vertical part of each beam when tilted, relative to a level ADCP
+	rot := earthRotation(0, float64(anc.Pitch), float64(anc.Roll))
+	cosAngle := math.Cos(beamAngle * (math.Pi / 180.0))
+	bad := mapped.Base.badValue()
+
+	for b, dir := range dirs {
+		scale := math.Abs(rot[2][0]*dir[0]+rot[2][1]*dir[1]+rot[2][2]*dir[2]) / cosAngle
+
+		for bin := 0; bin < mapped.Velocity.NumBins; bin++ {
+			// Beam sample at the level range of the bin
+			value := bad
+			if scale > 0 && anc.BinSize > 0 {
+				levelRange := float64(anc.FirstBinRange) + float64(bin)*float64(anc.BinSize)
+				sample := int(math.Round((levelRange/scale - float64(anc.FirstBinRange)) / float64(anc.BinSize)))
+				if sample >= 0 && sample < beam.Velocity.NumBins {
+					value = beam.Velocity.At(sample, b)
+				}
+			}
+
+			mapped.Velocity.Set(bin, b, value)
+		}
+	}
+
+	return mapped, nil
+}
+
+// beamDirections will get the unit vector of each beam in instrument coordinates.
+// The beam layout is the same as BeamToInstrumentMatrix.
+func beamDirections(beamAngle float64, numBeams int, beamOffset float64) ([][3]float64, error) {
+	angle := beamAngle * (math.Pi / 180.0)
+	sin, cos := math.Sin(angle), math.Cos(angle)
+
+	var dirs [][3]float64
+	switch numBeams {
+	case 4:
+		dirs = [][3]float64{
+			{sin, 0, cos},
+			{-sin, 0, cos},
+			{0, -sin, cos},
+			{0, sin, cos},
+		}
+	case 3:
+		for beam := 0; beam < 3; beam++ {
+			azimuth := float64(beam) * (2.0 * math.Pi / 3.0)
+			dirs = append(dirs, [3]float64{sin * math.Cos(azimuth), sin * math.Sin(azimuth), cos})
+		}
+	default:
+		return nil, fmt.Errorf("can not map bins for %d beams", numBeams)
+	}
+
+	// Rotate by the beam offset about the Z axis
+	offset := beamOffset * (math.Pi / 180.0)
+	for i, d := range dirs {
+		dirs[i][0] = d[0]*math.Cos(offset) - d[1]*math.Sin(offset)
+		dirs[i][1] = d[0]*math.Sin(offset) + d[1]*math.Cos(offset)
+	}
+
+	return dirs, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"log"
+	"math"
 	"runtime/debug"
 	"strings"
 	"sync"
@@ -50,6 +51,22 @@ const goodEarthID = "E000007"          // Good Earth Dataset ID
 const ensembleDataID = "E000008"       // Ensemble Dataset ID
 const ancillaryID = "E000009"          // Ancillary Dataset ID
 const bottomTrackID = "E000010"        // Bottom Track Dataset ID
+const nmeaID = "E000011"               // NMEA Dataset ID
+
+// Dataset IDs used to select the datasets to decode in the DecodeOptions.
+const (
+	BeamVelocityID       = beamVelocityID       // Beam Velocity Dataset ID
+	InstrumentVelocityID = instrumentVelocityID // Instrument Velocity Dataset ID
+	EarthVelocityID      = earthVelocityID      // Earth Velocity Dataset ID
+	AmplitudeID          = amplitudeID          // Amplitude Dataset ID
+	CorrelationID        = correlationID        // Correlation Dataset ID
+	GoodBeamID           = goodBeamID           // Good Beam Dataset ID
+	GoodEarthID          = goodEarthID          // Good Earth Dataset ID
+	EnsembleDataID       = ensembleDataID       // Ensemble Dataset ID
+	AncillaryID          = ancillaryID          // Ancillary Dataset ID
+	BottomTrackID        = bottomTrackID        // Bottom Track Dataset ID
+	NmeaID               = nmeaID               // NMEA Dataset ID
+)
 
 var ensembleSize uint32 // Current ensemble size
 var ensembleNum uint32  // Current ensemble Number
@@ -62,6 +79,7 @@ type BinaryCodec struct {
 	Write          chan []byte   // Write binary data to be decoded
 	Read           chan Ensemble // Read out ensembles decoded
 	IsClosing      bool          // Flag to stop decoding data
+	Options        DecodeOptions // Options to decode the ensembles
 	bufferIncoming chan []byte   // Buffer the incoming data to decode
 }
 
@@ -192,14 +210,15 @@ func decodeIncomingData(codec *BinaryCodec) {
 	if uint32(cal1Checksum) == checksum {
 		//log.Printf("Ensemble number: %d  Ensemble size: %d", ensembleNum, len(ens))
 		// Decode the ensemble
-		ensemble := decodeEnsemble(ens)
+		ensemble := decodeEnsemble(ens, &codec.Options)
 
 		log.Print("Ensemble decoded")
 
 		// Publish the ensemble
 		codec.Read <- ensemble
 
-		log.Printf("Ensemble number: %d Number of Beams: %d  Number of Bins: %d", ensemble.EnsembleData.EnsembleNumber, ensemble.EnsembleData.NumBeams, ensemble.EnsembleData.NumBins)
+		ensData := ensemble.GetEnsembleData()
+		log.Printf("Ensemble number: %d Number of Beams: %d  Number of Bins: %d", ensData.EnsembleNumber, ensData.NumBeams, ensData.NumBins)
 		//
 		// ensJSON, _ := json.Marshal(ensemble)
 		//
@@ -211,7 +230,10 @@ func decodeIncomingData(codec *BinaryCodec) {
 	debug.FreeOSMemory()
 }
 
-func decodeEnsemble(data []byte) Ensemble {
+// decodeEnsemble will decode all the datasets in the ensemble.
+// Only the datasets selected in the options are decoded now.  All other
+// datasets keep their binary data and are decoded on first access.
+func decodeEnsemble(data []byte, opts *DecodeOptions) Ensemble {
 	// Keep track where in the packet
 	// we are currently decoding
 	var packetPointer = hdlen
@@ -267,9 +289,10 @@ func decodeEnsemble(data []byte) Ensemble {
 			ensemble.EnsembleData.Base.Imag = imag
 			ensemble.EnsembleData.Base.NameLen = nameLen
 			ensemble.EnsembleData.Base.Name = name
+			ensemble.EnsembleData.Location = opts.Location
 
 			// Ensemble Data Set
-			ensemble.EnsembleData.Decode(data[packetPointer : packetPointer+dataSetSize])
+			decodeDataSet(&ensemble.EnsembleData.Base, ensemble.EnsembleData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
 
 			// Move to the next dataset
 			packetPointer += dataSetSize
@@ -283,7 +306,7 @@ func decodeEnsemble(data []byte) Ensemble {
 			ensemble.AncillaryData.Base.Name = name
 
 			// Ancillary Data Set
-			ensemble.AncillaryData.Decode(data[packetPointer : packetPointer+dataSetSize])
+			decodeDataSet(&ensemble.AncillaryData.Base, ensemble.AncillaryData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
 
 			// Move to the next dataset
 			packetPointer += dataSetSize
@@ -297,7 +320,7 @@ func decodeEnsemble(data []byte) Ensemble {
 			ensemble.BeamVelocityData.Base.Name = name
 
 			// Ensemble Data Set
-			ensemble.BeamVelocityData.Decode(data[packetPointer : packetPointer+dataSetSize])
+			decodeDataSet(&ensemble.BeamVelocityData.Base, ensemble.BeamVelocityData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
 
 			// Move to the next dataset
 			packetPointer += dataSetSize
@@ -311,7 +334,7 @@ func decodeEnsemble(data []byte) Ensemble {
 			ensemble.InstrumentVelocityData.Base.Name = name
 
 			// Ensemble Data Set
-			ensemble.InstrumentVelocityData.Decode(data[packetPointer : packetPointer+dataSetSize])
+			decodeDataSet(&ensemble.InstrumentVelocityData.Base, ensemble.InstrumentVelocityData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
 
 			// Move to the next dataset
 			packetPointer += dataSetSize
@@ -323,9 +346,10 @@ func decodeEnsemble(data []byte) Ensemble {
 			ensemble.EarthVelocityData.Base.Imag = imag
 			ensemble.EarthVelocityData.Base.NameLen = nameLen
 			ensemble.EarthVelocityData.Base.Name = name
+			ensemble.EarthVelocityData.HeadingOffset = opts.HeadingOffset
 
 			// Ensemble Data Set
-			ensemble.EarthVelocityData.Decode(data[packetPointer : packetPointer+dataSetSize])
+			decodeDataSet(&ensemble.EarthVelocityData.Base, ensemble.EarthVelocityData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
 
 			// Move to the next dataset
 			packetPointer += dataSetSize
@@ -339,7 +363,7 @@ func decodeEnsemble(data []byte) Ensemble {
 			ensemble.AmplitudeData.Base.Name = name
 
 			// Ensemble Data Set
-			ensemble.AmplitudeData.Decode(data[packetPointer : packetPointer+dataSetSize])
+			decodeDataSet(&ensemble.AmplitudeData.Base, ensemble.AmplitudeData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
 
 			// Move to the next dataset
 			packetPointer += dataSetSize
@@ -353,7 +377,63 @@ func decodeEnsemble(data []byte) Ensemble {
 			ensemble.CorrelationData.Base.Name = name
 
 			// Ensemble Data Set
-			ensemble.CorrelationData.Decode(data[packetPointer : packetPointer+dataSetSize])
+			decodeDataSet(&ensemble.CorrelationData.Base, ensemble.CorrelationData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
+
+			// Move to the next dataset
+			packetPointer += dataSetSize
+		} else if strings.Contains(name, goodBeamID) {
+			// Base
+			ensemble.GoodBeamData.Base.Enstype = enstype
+			ensemble.GoodBeamData.Base.NumElements = numElements
+			ensemble.GoodBeamData.Base.ElementMultiplier = elementMultiplier
+			ensemble.GoodBeamData.Base.Imag = imag
+			ensemble.GoodBeamData.Base.NameLen = nameLen
+			ensemble.GoodBeamData.Base.Name = name
+
+			// Good Beam Data Set
+			decodeDataSet(&ensemble.GoodBeamData.Base, ensemble.GoodBeamData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
+
+			// Move to the next dataset
+			packetPointer += dataSetSize
+		} else if strings.Contains(name, goodEarthID) {
+			// Base
+			ensemble.GoodEarthData.Base.Enstype = enstype
+			ensemble.GoodEarthData.Base.NumElements = numElements
+			ensemble.GoodEarthData.Base.ElementMultiplier = elementMultiplier
+			ensemble.GoodEarthData.Base.Imag = imag
+			ensemble.GoodEarthData.Base.NameLen = nameLen
+			ensemble.GoodEarthData.Base.Name = name
+
+			// Good Earth Data Set
+			decodeDataSet(&ensemble.GoodEarthData.Base, ensemble.GoodEarthData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
+
+			// Move to the next dataset
+			packetPointer += dataSetSize
+		} else if strings.Contains(name, bottomTrackID) {
+			// Base
+			ensemble.BottomTrackData.Base.Enstype = enstype
+			ensemble.BottomTrackData.Base.NumElements = numElements
+			ensemble.BottomTrackData.Base.ElementMultiplier = elementMultiplier
+			ensemble.BottomTrackData.Base.Imag = imag
+			ensemble.BottomTrackData.Base.NameLen = nameLen
+			ensemble.BottomTrackData.Base.Name = name
+
+			// Bottom Track Data Set
+			decodeDataSet(&ensemble.BottomTrackData.Base, ensemble.BottomTrackData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
+
+			// Move to the next dataset
+			packetPointer += dataSetSize
+		} else if strings.Contains(name, nmeaID) {
+			// Base
+			ensemble.NmeaData.Base.Enstype = enstype
+			ensemble.NmeaData.Base.NumElements = numElements
+			ensemble.NmeaData.Base.ElementMultiplier = elementMultiplier
+			ensemble.NmeaData.Base.Imag = imag
+			ensemble.NmeaData.Base.NameLen = nameLen
+			ensemble.NmeaData.Base.Name = name
+
+			// NMEA Data Set
+			decodeDataSet(&ensemble.NmeaData.Base, ensemble.NmeaData.Decode, data[packetPointer:packetPointer+dataSetSize], opts)
 
 			// Move to the next dataset
 			packetPointer += dataSetSize
@@ -369,37 +449,81 @@ func decodeEnsemble(data []byte) Ensemble {
 
 	}
 
+	// Magnetic declination for the Earth velocities
+	// The model declination needs the ensemble time and GPS position, so it
+	// is computed when the Earth velocities are decoded
+	if opts.MagneticModel == nil {
+		ensemble.EarthVelocityData.addDeclination(opts.Declination)
+	} else if ensemble.EarthVelocityData.Base.IsPending() {
+		ensemble.declinationOpts = opts
+	} else {
+		opts.applyDeclination(&ensemble)
+	}
+
 	return ensemble
 
 }
 
+// decodeDataSet will decode the dataset if the options decode it eagerly.
+// Otherwise the binary data is kept in the base dataset and the dataset
+// is decoded on first access.
+func decodeDataSet(base *BaseDataSet, decode func([]byte), data []byte, opts *DecodeOptions) {
+	base.badToNaN = opts.BadToNaN
+
+	if opts.IsEager(base.Name) {
+		decode(data)
+		return
+	}
+
+	base.raw = data
+}
+
 // GenerateIndex will find the location of the data within
 // the slice based off the index value given.
 func GenerateIndex(index int, nameLen uint32, ensType uint32) int {
-	datatype := BytesInFloat
+	return getHeaderSize(nameLen) + (index * getDataTypeSize(ensType))
+}
+
+// hasElements will check if the dataset has the number of elements
+// and there is enough data to decode them.
+func hasElements(base *BaseDataSet, data []byte, count int) bool {
+	return int(base.NumElements) >= count && len(data) >= GenerateIndex(count, base.NameLen, base.Enstype)
+}
+
+// GetBinBeamIndex will get the index in the data for the value.
+// This is used for data with bin data, like velocity, correlation and amplitde.
+// The data is assumed to be float values.
+func GetBinBeamIndex(nameLen int, numBins int, beam int, bin int) int {
+	return GetBinBeamTypeIndex(nameLen, numBins, beam, bin, dataTypeFloat)
+}
+
+// GetBinBeamTypeIndex will get the index in the data for the value.
+// This is used for data with bin data, like velocity, correlation and amplitde.
+// The size of each value is based off the data type given.
+func GetBinBeamTypeIndex(nameLen int, numBins int, beam int, bin int, ensType uint32) int {
+	datatype := getDataTypeSize(ensType)
+	return getHeaderSize(uint32(nameLen)) + (beam * numBins * datatype) + (bin * datatype)
+}
+
+// getBinBeamValue will get the bin and beam value from the data.
+// The value will be decoded based off the data type given.
+func getBinBeamValue(data []byte, nameLen int, numBins int, beam int, bin int, ensType uint32) float64 {
+	ptr := GetBinBeamTypeIndex(nameLen, numBins, beam, bin, ensType)
 
 	switch ensType {
 	case dataTypeByte:
-		datatype = BytesInInt8
-		break
+		return float64(data[ptr])
 	case dataTypeInt:
-		datatype = BytesInInt32
-		break
-	case dataTypeFloat:
-		datatype = BytesInFloat
-		break
+		return float64(int32(binary.LittleEndian.Uint32(data[ptr : ptr+BytesInInt32])))
 	default:
-		datatype = BytesInFloat
-		break
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data[ptr : ptr+BytesInFloat])))
 	}
-
-	return getHeaderSize(nameLen) + (index * datatype)
 }
 
-// GetBinBeamIndex will get the index in the data for the value.
-// This is used for data with bin data, like velocity, correlation and amplitde.
-func GetBinBeamIndex(nameLen int, numBins int, beam int, bin int) int {
-	return getHeaderSize(uint32(nameLen)) + (beam * numBins * BytesInFloat) + (bin * BytesInFloat)
+// hasBinBeamData will check if there is enough data to decode
+// all the bins and beams for the dataset.
+func hasBinBeamData(base *BaseDataSet, data []byte) bool {
+	return len(data) >= getDataSetSize(base.Enstype, base.NameLen, base.NumElements, base.ElementMultiplier)
 }
 
 // getDataSetSize will get the size of the dataset.  It will user the number of elements and the
@@ -409,25 +533,22 @@ func GetBinBeamIndex(nameLen int, numBins int, beam int, bin int) int {
 // Bin data: bins x beams
 // Other data: numElements x 1
 func getDataSetSize(ensType uint32, nameLen uint32, numElements uint32, elementMultiplier uint32) int {
+	return ((int(numElements) * int(elementMultiplier)) * getDataTypeSize(ensType)) + getHeaderSize(nameLen)
+}
 
-	datatype := BytesInFloat
-
+// getDataTypeSize will get the number of bytes for each element
+// based off the data type.  If the data type is not known, float is used.
+func getDataTypeSize(ensType uint32) int {
 	switch ensType {
 	case dataTypeByte:
-		datatype = BytesInInt8
-		break
+		return BytesInInt8
 	case dataTypeInt:
-		datatype = BytesInInt32
-		break
+		return BytesInInt32
 	case dataTypeFloat:
-		datatype = BytesInFloat
-		break
+		return BytesInFloat
 	default:
-		datatype = BytesInFloat
-		break
+		return BytesInFloat
 	}
-
-	return ((int(numElements) * int(elementMultiplier)) * int(datatype)) + getHeaderSize(nameLen)
 }
 
 // getHeaderSize will get the number bytes in the header.
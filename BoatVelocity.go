@@ -0,0 +1,228 @@
+package rti
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// VelocityReference is the reference used to remove the boat motion
+// from the Earth velocities.
+type VelocityReference int
+
+// Velocity references
+const (
+	ReferenceNone        VelocityReference = iota // No reference, the velocities are relative to the ADCP
+	ReferenceBottomTrack                          // Bottom Track Earth velocity
+	ReferenceGPSVTG                               // GPS course and speed over ground from the VTG sentence
+	ReferenceGPSGGA                               // GPS velocity from the change in the GGA position between ensembles
+)
+
+// String will get the name of the reference.
+func (ref VelocityReference) String() string {
+	switch ref {
+	case ReferenceBottomTrack:
+		return "Bottom Track"
+	case ReferenceGPSVTG:
+		return "GPS VTG"
+	case ReferenceGPSGGA:
+		return "GPS GGA"
+	default:
+		return "None"
+	}
+}
+
+// DefaultReferencePriority is bottom track first, then the GPS velocities.
+var DefaultReferencePriority = []VelocityReference{ReferenceBottomTrack, ReferenceGPSVTG, ReferenceGPSGGA}
+
+// ReferenceProcessor will remove the boat motion from the Earth velocities of
+// each ensemble so the velocities are relative to the earth.  The reference
+// for each ensemble is the first reference in the priority list that is
+// available.  The ensembles must be processed in time order, because the
+// GGA reference uses the position of the previous ensemble.
+//
+// RTI sign convention
+// The Earth velocities and the Bottom Track Earth velocity are measured relative
+// to the ADCP.  The Bottom Track velocity is the velocity of the bottom relative
+// to the ADCP, which is the opposite of the boat velocity.  A boat moving North
+// at 1 m/s over still water measures a Bottom Track and water velocity of 1 m/s
+// South.  The reference is the same for every source, so the boat velocity is
+// always the opposite of the reference.
+// Water = Measured - Bottom Track
+// Water = Measured + Boat (GPS)
+// Boat = -Reference
+type ReferenceProcessor struct {
+	Priority         []VelocityReference // References in order of priority.  If empty, DefaultReferencePriority is used
+	MinGPSQuality    int                 // Minimum GGA fix quality to use the GPS.  If 0, any fix is used
+	MaxHDOP          float64             // Maximum GGA HDOP to use the GPS.  If 0, HDOP is not checked
+	KeepUnreferenced bool                // If no reference is available, keep the relative velocities instead of marking them bad
+
+	prevTime     time.Time // Time of the previous GGA position
+	prevPosition *Position // Previous GGA position
+}
+
+// Apply will remove the boat motion from the Earth velocities of the ensemble.
+// The reference used is returned.  If no reference is available, the velocities
+// are marked bad unless KeepUnreferenced is set, and ReferenceNone is returned.
+// The Bottom Track velocity must be good in the East and North to be used.
+func (proc *ReferenceProcessor) Apply(ens *Ensemble) (VelocityReference, error) {
+	earth := ens.GetEarthVelocityData()
+	if earth.Reference != ReferenceNone {
+		return earth.Reference, errors.New("earth velocities already have the boat motion removed")
+	}
+
+	if ref, east, north, vertical := proc.reference(ens); ref != ReferenceNone {
+		removeReference(earth, east, north, vertical)
+		earth.Reference = ref
+		return ref, nil
+	}
+
+	// No reference
+	// Only the Earth velocities are bad, the beam velocities are still good
+	if !proc.KeepUnreferenced {
+		for bin := 0; bin < earth.Velocity.NumBins; bin++ {
+			ens.addScreenReason(bin, ScreenNoReference)
+			markBad(&earth.Base, earth.Velocity, bin)
+		}
+		earth.calcVectors()
+	}
+
+	return ReferenceNone, nil
+}
+
+// reference will get the first reference in the priority list that is available
+// and its East, North and vertical velocity in m/s.  The reference velocity is the
+// opposite of the boat velocity.  If no reference is available, ReferenceNone is returned.
+func (proc *ReferenceProcessor) reference(ens *Ensemble) (VelocityReference, float64, float64, float64) {
+	earth := ens.GetEarthVelocityData()
+	ggaVel, hasGGA := proc.ggaVelocity(ens)
+
+	priority := proc.Priority
+	if len(priority) == 0 {
+		priority = DefaultReferencePriority
+	}
+
+	for _, ref := range priority {
+		var east, north, vertical float64
+		var ok bool
+
+		switch ref {
+		case ReferenceBottomTrack:
+			east, north, vertical, ok = bottomTrackReference(ens, earth)
+		case ReferenceGPSVTG:
+			east, north, ok = proc.vtgReference(ens)
+		case ReferenceGPSGGA:
+			east, north, ok = -ggaVel[0], -ggaVel[1], hasGGA
+		}
+
+		if ok {
+			return ref, east, north, vertical
+		}
+	}
+
+	return ReferenceNone, 0, 0, 0
+}
+
+// bottomTrackReference will get the Bottom Track Earth velocity rotated by the same
+// declination and heading offset as the Earth velocities.
+func bottomTrackReference(ens *Ensemble, earth *EarthVelocityDataSet) (float64, float64, float64, bool) {
+	bt := ens.GetBottomTrackData()
+	if len(bt.EarthVelocity) <= EarthVertical {
+		return 0, 0, 0, false
+	}
+
+	east := bt.EarthVelocity[EarthEast]
+	north := bt.EarthVelocity[EarthNorth]
+	vertical := bt.EarthVelocity[EarthVertical]
+	if IsBad(east) || IsBad(north) {
+		return 0, 0, 0, false
+	}
+	if IsBad(vertical) {
+		vertical = 0
+	}
+
+	sin, cos := math.Sincos(float64(earth.Declination+earth.HeadingOffset) * (math.Pi / 180.0))
+	e, n := float64(east), float64(north)
+
+	return e*cos + n*sin, -e*sin + n*cos, float64(vertical), true
+}
+
+// vtgReference will get the reference from the GPS course and speed.
+// The reference is the opposite of the boat velocity.
+func (proc *ReferenceProcessor) vtgReference(ens *Ensemble) (float64, float64, bool) {
+	nmea := ens.GetNmeaData()
+	if !nmea.HasVelocity || !proc.goodFix(nmea) {
+		return 0, 0, false
+	}
+
+	sin, cos := math.Sincos(nmea.Course * (math.Pi / 180.0))
+	return -nmea.Speed * sin, -nmea.Speed * cos, true
+}
+
+// ggaVelocity will get the boat velocity East and North in m/s from the change in
+// the GGA position since the previous ensemble.  The position is stored for the
+// next ensemble.
+func (proc *ReferenceProcessor) ggaVelocity(ens *Ensemble) ([2]float64, bool) {
+	nmea := ens.GetNmeaData()
+	t, err := ens.GetEnsembleData().Time()
+	if err != nil || !nmea.HasPosition || !proc.goodFix(nmea) {
+		return [2]float64{}, false
+	}
+
+	prevTime, prev := proc.prevTime, proc.prevPosition
+	pos := nmea.Position
+	proc.prevTime, proc.prevPosition = t, &pos
+
+	dt := t.Sub(prevTime).Seconds()
+	if prev == nil || dt <= 0 {
+		return [2]float64{}, false
+	}
+
+	east, north := flatEarthDistance(*prev, pos)
+	return [2]float64{east / dt, north / dt}, true
+}
+
+// flatEarthDistance will get the East and North distance in meters between 2 positions.
+// The earth is assumed flat between the positions.
+func flatEarthDistance(from Position, to Position) (float64, float64) {
+	deg := math.Pi / 180.0
+	meanLat := (to.Latitude + from.Latitude) / 2.0 * deg
+	east := (to.Longitude - from.Longitude) * deg * wgs84A * 1000.0 * math.Cos(meanLat)
+	north := (to.Latitude - from.Latitude) * deg * wgs84A * 1000.0
+
+	return east, north
+}
+
+// goodFix will check the GGA fix quality and HDOP.
+func (proc *ReferenceProcessor) goodFix(nmea *NmeaDataSet) bool {
+	if !nmea.HasPosition {
+		return proc.MinGPSQuality == 0 && proc.MaxHDOP == 0
+	}
+	if nmea.FixQuality < proc.MinGPSQuality {
+		return false
+	}
+
+	return proc.MaxHDOP == 0 || nmea.HDOP <= proc.MaxHDOP
+}
+
+// removeReference will subtract the reference from the good velocities in every bin.
+func removeReference(earth *EarthVelocityDataSet, east float64, north float64, vertical float64) {
+	if earth.Velocity.NumBeams <= EarthVertical {
+		return
+	}
+
+	for bin := 0; bin < earth.Velocity.NumBins; bin++ {
+		vel := earth.Velocity.Bin(bin)
+		if IsBad(vel[EarthEast]) || IsBad(vel[EarthNorth]) {
+			continue
+		}
+
+		vel[EarthEast] = float32(float64(vel[EarthEast]) - east)
+		vel[EarthNorth] = float32(float64(vel[EarthNorth]) - north)
+		if !IsBad(vel[EarthVertical]) {
+			vel[EarthVertical] = float32(float64(vel[EarthVertical]) - vertical)
+		}
+	}
+
+	earth.calcVectors()
+}
@@ -0,0 +1,62 @@
+package rti
+
+import (
+	"math"
+	"testing"
+)
+
+// newBoatEnsemble will create an ensemble for a boat moving over the water.  The
+// Earth and Bottom Track velocities are relative to the ADCP like an RTI ADCP
+// measures them and the GPS VTG is the boat course and speed.
+func newBoatEnsemble(waterEast, waterNorth, boatEast, boatNorth float64) Ensemble {
+	var ens Ensemble
+	ens.EnsembleData = EnsembleDataSet{Year: 2024, Month: 1, Day: 1}
+
+	ens.EarthVelocityData.Base.ElementMultiplier = numVelocityComponents
+	ens.EarthVelocityData.Velocity = NewBinBeamMatrix[float32](1, numVelocityComponents)
+	ens.EarthVelocityData.Velocity.Set(0, EarthEast, float32(waterEast-boatEast))
+	ens.EarthVelocityData.Velocity.Set(0, EarthNorth, float32(waterNorth-boatNorth))
+
+	ens.BottomTrackData.EarthVelocity = []float32{float32(-boatEast), float32(-boatNorth), 0, 0}
+
+	ens.NmeaData.HasVelocity = true
+	ens.NmeaData.Course = compassDirection(boatEast, boatNorth)
+	ens.NmeaData.Speed = math.Hypot(boatEast, boatNorth)
+
+	return ens
+}
+
+// TestReferenceSign will check the water velocity and the boat velocity for a
+// known boat motion with each reference.
+func TestReferenceSign(t *testing.T) {
+	tests := []struct {
+		waterEast, waterNorth float64
+		boatEast, boatNorth   float64
+	}{
+		{0, 0, 0, 1},      // Boat North over still water
+		{0.5, 0, 0, 1},    // Boat North across an East current
+		{-0.3, 0.2, 2, 0}, // Boat East
+	}
+
+	for _, test := range tests {
+		for _, ref := range []VelocityReference{ReferenceBottomTrack, ReferenceGPSVTG} {
+			ens := newBoatEnsemble(test.waterEast, test.waterNorth, test.boatEast, test.boatNorth)
+			proc := ReferenceProcessor{Priority: []VelocityReference{ref}}
+
+			// Boat velocity is the opposite of the reference
+			got, east, north, _ := proc.reference(&ens)
+			if got != ref || math.Abs(-east-test.boatEast) > 1e-6 || math.Abs(-north-test.boatNorth) > 1e-6 {
+				t.Errorf("%s: boat velocity %.3f %.3f, want %.3f %.3f", ref, -east, -north, test.boatEast, test.boatNorth)
+			}
+
+			// Water velocity relative to the earth
+			if _, err := proc.Apply(&ens); err != nil {
+				t.Fatal(err)
+			}
+			vel := ens.EarthVelocityData.Velocity
+			if math.Abs(float64(vel.At(0, EarthEast))-test.waterEast) > 1e-6 || math.Abs(float64(vel.At(0, EarthNorth))-test.waterNorth) > 1e-6 {
+				t.Errorf("%s: water velocity %.3f %.3f, want %.3f %.3f", ref, vel.At(0, EarthEast), vel.At(0, EarthNorth), test.waterEast, test.waterNorth)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package rti
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// numBottomTrackElements is the number of values before the beam values.
+const numBottomTrackElements = 14
+
+// numBottomTrackBeamValues is the number of values for each beam.
+const numBottomTrackBeamValues = 10
+
+// BottomTrackDataSet will contain all the Bottom Track Data set values.
+// The bottom track ping measures the range to the bottom and the velocity
+// of the ADCP over the bottom.  Each slice has a value for each beam.
+type BottomTrackDataSet struct {
+	Base               BaseDataSet // Base Dataset
+	FirstPingTime      float32     // First ping time in seconds
+	LastPingTime       float32     // Last ping time in seconds
+	Heading            float32     // Heading in degrees
+	Pitch              float32     // Pitch in degrees
+	Roll               float32     // Roll in degrees
+	WaterTemp          float32     // Water temperature in degrees Celsius
+	SystemTemp         float32     // System temperature in degrees Celsius
+	Salinity           float32     // Salinity in Parts per Thousand (PPT)
+	Pressure           float32     // Pressure in Pascals
+	TransducerDepth    float32     // Depth of the transducer in water in meters
+	SpeedOfSound       float32     // Speed of Sound in m/s
+	Status             Status      // Bottom Track status
+	NumBeams           int         // Number of beams
+	ActualPingCount    float32     // Actual number of pings
+	Range              []float32   // Range to the bottom in meters for each beam
+	SNR                []float32   // Signal to noise ratio in dB for each beam
+	Amplitude          []float32   // Amplitude in dB for each beam
+	Correlation        []float32   // Correlation for each beam
+	BeamVelocity       []float32   // Beam velocity in m/s for each beam
+	BeamGood           []float32   // Number of good pings for each beam
+	InstrumentVelocity []float32   // Instrument velocity in m/s for X, Y, Z and Error
+	InstrumentGood     []float32   // Number of good pings for X, Y, Z and Error
+	EarthVelocity      []float32   // Earth velocity in m/s for East, North, Vertical and Error
+	EarthGood          []float32   // Number of good pings for East, North, Vertical and Error
+}
+
+// Decode will take the binary data and decode into
+// into the ensemble data set.
+func (bt *BottomTrackDataSet) Decode(data []byte) {
+
+	// Not enough data
+	if !hasElements(&bt.Base, data, numBottomTrackElements) {
+		return
+	}
+
+	bt.FirstPingTime = bt.value(data, 0)
+	bt.LastPingTime = bt.value(data, 1)
+	bt.Heading = bt.value(data, 2)
+	bt.Pitch = bt.value(data, 3)
+	bt.Roll = bt.value(data, 4)
+	bt.WaterTemp = bt.value(data, 5)
+	bt.SystemTemp = bt.value(data, 6)
+	bt.Salinity = bt.value(data, 7)
+	bt.Pressure = bt.value(data, 8)
+	bt.TransducerDepth = bt.value(data, 9)
+	bt.SpeedOfSound = bt.value(data, 10)
+	bt.Status = Status(bt.value(data, 11))
+	bt.NumBeams = int(bt.value(data, 12))
+	bt.ActualPingCount = bt.value(data, 13)
+
+	// Not enough data for the beam values
+	if bt.NumBeams < 0 || !hasElements(&bt.Base, data, numBottomTrackElements+numBottomTrackBeamValues*bt.NumBeams) {
+		bt.NumBeams = 0
+		return
+	}
+
+	bt.Range = bt.beamValues(data, 0)
+	bt.SNR = bt.beamValues(data, 1)
+	bt.Amplitude = bt.beamValues(data, 2)
+	bt.Correlation = bt.beamValues(data, 3)
+	bt.BeamVelocity = bt.beamValues(data, 4)
+	bt.BeamGood = bt.beamValues(data, 5)
+	bt.InstrumentVelocity = bt.beamValues(data, 6)
+	bt.InstrumentGood = bt.beamValues(data, 7)
+	bt.EarthVelocity = bt.beamValues(data, 8)
+	bt.EarthGood = bt.beamValues(data, 9)
+
+	if bt.Base.badToNaN {
+		for _, values := range [][]float32{bt.Range, bt.BeamVelocity, bt.InstrumentVelocity, bt.EarthVelocity} {
+			for i, v := range values {
+				if IsBad(v) {
+					values[i] = float32(math.NaN())
+				}
+			}
+		}
+	}
+}
+
+// value will decode the float value at the index.
+func (bt *BottomTrackDataSet) value(data []byte, index int) float32 {
+	ptr := GenerateIndex(index, bt.Base.NameLen, bt.Base.Enstype)
+	bits := binary.LittleEndian.Uint32(data[ptr : ptr+BytesInFloat])
+	return math.Float32frombits(bits)
+}
+
+// beamValues will decode the values for each beam in the group.
+// The groups are stored one after the other after the ensemble values.
+func (bt *BottomTrackDataSet) beamValues(data []byte, group int) []float32 {
+	values := make([]float32, bt.NumBeams)
+	for beam := range values {
+		values[beam] = bt.value(data, numBottomTrackElements+group*bt.NumBeams+beam)
+	}
+
+	return values
+}
+
+// HasBottom will check if any beam found the bottom.
+func (bt *BottomTrackDataSet) HasBottom() bool {
+	for _, r := range bt.Range {
+		if !IsBad(r) && r > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MinRange will get the shortest good range to the bottom in meters.
+// If no beam found the bottom, false is returned.
+func (bt *BottomTrackDataSet) MinRange() (float32, bool) {
+	min := float32(math.MaxFloat32)
+	found := false
+	for _, r := range bt.Range {
+		if !IsBad(r) && r > 0 && r < min {
+			min = r
+			found = true
+		}
+	}
+
+	return min, found
+}
+
+// AverageRange will get the average of the good ranges to the bottom in meters.
+// If no beam found the bottom, false is returned.
+func (bt *BottomTrackDataSet) AverageRange() (float32, bool) {
+	var sum float32
+	count := 0
+	for _, r := range bt.Range {
+		if !IsBad(r) && r > 0 {
+			sum += r
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+
+	return sum / float32(count), true
+}
@@ -0,0 +1,61 @@
+package rti
+
+import (
+	"strings"
+	"time"
+)
+
+// DecodeOptions will set how the ensembles are decoded.
+// Decoding every velocity, amplitude and correlation dataset is expensive.
+// If only some datasets are needed, select them in Eager.  All the other
+// datasets keep their binary data and are decoded on first access.
+type DecodeOptions struct {
+	Eager    []string       // Dataset IDs to decode with the ensemble.  If empty, all datasets are decoded.
+	Location *time.Location // Time zone of the instrument clock.  If nil, UTC is used.
+	BadToNaN bool           // Convert the bad values in the bin datasets to NaN
+
+	Declination   float32 // Magnetic declination in degrees to rotate the Earth velocities
+	HeadingOffset float32 // Heading offset in degrees to rotate the Earth velocities
+
+	// Automatic magnetic declination
+	// If MagneticModel is set, the declination is computed from the model at the GPS
+	// position in the NMEA data or the deployment Position and the ensemble time.
+	// Declination is only used if the model can not be evaluated or the ensemble
+	// time is outside the life of the model.  The Earth Velocity Data Set
+	// DeclinationFromModel flag tells which one was used.
+	MagneticModel *MagneticModel // Magnetic model like WMM
+	Position      *Position      // Deployment position used when there is no GPS position
+}
+
+// applyDeclination will rotate the Earth velocities of the ensemble by the magnetic
+// declination.  If the model declination can not be computed, like an ensemble time
+// outside the life of the model, the fixed Declination is used and the Earth Velocity
+// Data Set DeclinationFromModel flag is false.
+func (opts *DecodeOptions) applyDeclination(ens *Ensemble) {
+	earth := &ens.EarthVelocityData
+	if opts.MagneticModel != nil {
+		if decl, err := ens.ModelDeclination(opts.MagneticModel, opts.Position); err == nil {
+			earth.DeclinationFromModel = true
+			earth.addDeclination(float32(decl))
+			return
+		}
+	}
+
+	earth.addDeclination(opts.Declination)
+}
+
+// IsEager will check if the dataset with the given name
+// is decoded with the ensemble.
+func (opts *DecodeOptions) IsEager(name string) bool {
+	if opts == nil || len(opts.Eager) == 0 {
+		return true
+	}
+
+	for _, id := range opts.Eager {
+		if strings.Contains(name, id) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,510 @@
+package rti
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// DespikeMethod is the algorithm used to find the spikes.
+type DespikeMethod int
+
+// Despike methods
+const (
+	DespikeGoringNikora DespikeMethod = iota // Goring and Nikora (2002) phase space thresholding
+	DespikeMAD                               // Median absolute deviation from the median
+	DespikeAcceleration                      // Acceleration from the last good value above a limit
+)
+
+// SpikeReplacement is how a spike is replaced.
+type SpikeReplacement int
+
+// Spike replacements
+const (
+	ReplaceBad         SpikeReplacement = iota // Flag the spike with the bad value of the dataset, BadVelocity or NaN if decoded with BadToNaN
+	ReplaceInterpolate                         // Linearly interpolate between the good values around the spike
+	ReplaceLastGood                            // Replace the spike with the last good value
+	ReplaceNaN                                 // Replace the spike with NaN
+)
+
+// Standard gravity in m/s^2
+const gravity = 9.80665
+
+// Default despiking thresholds
+const defaultMADThreshold = 3.0                // Number of scaled median absolute deviations
+const defaultAccelerationLimit = 1.5 * gravity // Acceleration limit in m/s^2
+const defaultDespikeIterations = 20            // Maximum phase space iterations
+const defaultMaxSpikeLength = 3                // Consecutive acceleration spikes before a step change
+
+// madScale will scale the median absolute deviation to the standard deviation of a normal distribution.
+const madScale = 1.4826
+
+// Despiker will remove the spikes from the velocity time series of each bin.
+type Despiker struct {
+	Method      DespikeMethod    // Algorithm to find the spikes
+	Replacement SpikeReplacement // How to replace the spikes
+
+	Threshold         float64       // Number of scaled MADs for DespikeMAD.  If 0, 3 is used
+	AccelerationLimit float64       // Acceleration limit in m/s^2 for DespikeAcceleration.  If 0, 1.5 g is used
+	SampleInterval    time.Duration // Time between the samples.  If 0, the median time between the ensembles is used
+	MaxIterations     int           // Maximum iterations for DespikeGoringNikora.  If 0, 20 is used
+	MaxSpikeLength    int           // Consecutive spikes for DespikeAcceleration before the values are a step change.  If 0, 3 is used
+}
+
+// Despike will remove the spikes from the time series of each bin and beam of the
+// Beam and Earth Velocity Data Sets in the ensembles.  The ensembles must be in
+// time order.  Ensembles with a different number of bins or beams than the first
+// ensemble are not used.  The number of spikes found is returned.  The acceleration
+// method needs the time between the samples, so an error is returned if there is
+// no SampleInterval and the ensembles have no valid times.
+func (d *Despiker) Despike(ensembles []Ensemble) (int, error) {
+	if len(ensembles) == 0 {
+		return 0, nil
+	}
+
+	dt := d.SampleInterval.Seconds()
+	if dt <= 0 {
+		dt = medianInterval(ensembles)
+	}
+	if d.Method == DespikeAcceleration && dt <= 0 {
+		return 0, errors.New("no sample interval for the acceleration despiking")
+	}
+
+	beam := make([]BinBeamMatrix[float32], len(ensembles))
+	earth := make([]BinBeamMatrix[float32], len(ensembles))
+	for i := range ensembles {
+		beam[i] = ensembles[i].GetBeamVelocityData().Velocity
+		earth[i] = ensembles[i].GetEarthVelocityData().Velocity
+	}
+
+	spikes := d.despikeMatrices(beam, dt, ensembles[0].BeamVelocityData.Base.badValue()) +
+		d.despikeMatrices(earth, dt, ensembles[0].EarthVelocityData.Base.badValue())
+
+	for i := range ensembles {
+		ensembles[i].EarthVelocityData.calcVectors()
+	}
+
+	return spikes, nil
+}
+
+// despikeMatrices will despike the time series of each bin and beam in the matrices.
+// The spikes that are not replaced are set to the bad value.
+func (d *Despiker) despikeMatrices(mats []BinBeamMatrix[float32], dt float64, bad float32) int {
+	numBins, numBeams := matrixSize(mats)
+
+	var used []BinBeamMatrix[float32]
+	for _, mat := range mats {
+		if mat.NumBins == numBins && mat.NumBeams == numBeams {
+			used = append(used, mat)
+		}
+	}
+
+	spikes := 0
+	series := make([]float32, len(used))
+	for i := 0; i < numBins*numBeams; i++ {
+		for j, mat := range used {
+			series[j] = mat.Data[i]
+		}
+
+		spikes += d.despikeSeries(series, dt, bad)
+
+		for j, mat := range used {
+			mat.Data[i] = series[j]
+		}
+	}
+
+	return spikes
+}
+
+// DespikeSeries will remove the spikes from the time series in place.  The time
+// between the samples is dt in seconds.  Bad values are not used and are not
+// changed.  The spikes that are not replaced are set to BadVelocity.  The number
+// of spikes found is returned.  The acceleration method returns an error if dt is not set.
+func (d *Despiker) DespikeSeries(series []float32, dt float64) (int, error) {
+	if d.Method == DespikeAcceleration && dt <= 0 {
+		return 0, errors.New("no sample interval for the acceleration despiking")
+	}
+
+	return d.despikeSeries(series, dt, BadVelocity), nil
+}
+
+// despikeSeries will remove the spikes from the time series in place.
+// The spikes that are not replaced are set to the bad value.
+func (d *Despiker) despikeSeries(series []float32, dt float64, bad float32) int {
+	var spikes []bool
+	switch d.Method {
+	case DespikeMAD:
+		spikes = d.madSpikes(series)
+	case DespikeAcceleration:
+		spikes = d.accelerationSpikes(series, dt)
+	default:
+		spikes = d.phaseSpaceSpikes(series)
+	}
+
+	return replaceSpikes(series, spikes, d.Replacement, bad)
+}
+
+// madSpikes will find the values further from the median than the threshold
+// number of scaled median absolute deviations.
+func (d *Despiker) madSpikes(series []float32) []bool {
+	threshold := d.Threshold
+	if threshold <= 0 {
+		threshold = defaultMADThreshold
+	}
+
+	spikes := make([]bool, len(series))
+	good := goodValues(series)
+	if len(good) < 3 {
+		return spikes
+	}
+
+	med := median(good)
+	dev := make([]float64, len(good))
+	for i, v := range good {
+		dev[i] = math.Abs(v - med)
+	}
+	mad := median(dev) * madScale
+	if mad == 0 {
+		return spikes
+	}
+
+	for i, v := range series {
+		if !IsBad(v) && math.Abs(float64(v)-med) > threshold*mad {
+			spikes[i] = true
+		}
+	}
+
+	return spikes
+}
+
+// accelerationSpikes will find the values where the acceleration from the last
+// good value is above the limit.  The time from the last good value does not count
+// the spikes after it, so each spike in a run is compared over one interval.  If
+// more than MaxSpikeLength values in a row are above the limit, the values are a
+// step change and not spikes, so they are kept and the next values are compared
+// with the new level.
+func (d *Despiker) accelerationSpikes(series []float32, dt float64) []bool {
+	limit := d.AccelerationLimit
+	if limit <= 0 {
+		limit = defaultAccelerationLimit
+	}
+	maxLength := d.MaxSpikeLength
+	if maxLength <= 0 {
+		maxLength = defaultMaxSpikeLength
+	}
+
+	spikes := make([]bool, len(series))
+	if dt <= 0 {
+		return spikes
+	}
+
+	last := -1
+	var run []int
+	for i, v := range series {
+		if IsBad(v) {
+			continue
+		}
+
+		if last >= 0 {
+			accel := math.Abs(float64(v)-float64(series[last])) / (float64(i-last-len(run)) * dt)
+			if accel > limit {
+				spikes[i] = true
+				run = append(run, i)
+				if len(run) <= maxLength {
+					continue
+				}
+
+				// Step change
+				for _, j := range run {
+					spikes[j] = false
+				}
+			}
+		}
+		run = run[:0]
+		last = i
+	}
+
+	return spikes
+}
+
+// phaseSpaceSpikes will find the spikes with the Goring and Nikora phase space method.
+// The value and its first and second derivatives are plotted against each other.
+// Values outside the ellipse of the universal threshold in any of the 3 projections
+// are spikes.  The spikes are replaced by interpolation and the method is repeated
+// until no new spikes are found.
+func (d *Despiker) phaseSpaceSpikes(series []float32) []bool {
+	maxIterations := d.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultDespikeIterations
+	}
+
+	n := len(series)
+	spikes := make([]bool, n)
+
+	// Working series with the bad values and spikes interpolated
+	work := make([]float32, n)
+	copy(work, series)
+	bad := make([]bool, n)
+	for i, v := range series {
+		bad[i] = IsBad(v)
+	}
+	if n-countTrue(bad) < 3 {
+		return spikes
+	}
+
+	lambda := math.Sqrt(2.0 * math.Log(float64(n)))
+
+	for iter := 0; iter < maxIterations; iter++ {
+		interpolateMissing(work, bad, spikes)
+
+		// Remove the mean
+		u := make([]float64, n)
+		mean := 0.0
+		for _, v := range work {
+			mean += float64(v)
+		}
+		mean /= float64(n)
+		for i, v := range work {
+			u[i] = float64(v) - mean
+		}
+
+		du := gradient(u)
+		d2u := gradient(du)
+
+		sdU, sdDu, sdD2u := stdDev(u), stdDev(du), stdDev(d2u)
+
+		// Rotation of the principal axis of u and d2u
+		var sumUD2u, sumU2 float64
+		for i := range u {
+			sumUD2u += u[i] * d2u[i]
+			sumU2 += u[i] * u[i]
+		}
+		theta := math.Atan(sumUD2u / sumU2)
+		sin, cos := math.Sincos(theta)
+
+		// Ellipse of u and d2u
+		c4s4 := cos*cos*cos*cos - sin*sin*sin*sin
+		a2 := ((lambda*sdU)*(lambda*sdU)*cos*cos - (lambda*sdD2u)*(lambda*sdD2u)*sin*sin) / c4s4
+		b2 := ((lambda*sdD2u)*(lambda*sdD2u)*cos*cos - (lambda*sdU)*(lambda*sdU)*sin*sin) / c4s4
+
+		found := false
+		for i := range u {
+			if bad[i] || spikes[i] {
+				continue
+			}
+
+			spike := outsideEllipse(u[i], du[i], lambda*sdU, lambda*sdDu, 0, 0) ||
+				outsideEllipse(du[i], d2u[i], lambda*sdDu, lambda*sdD2u, 0, 0) ||
+				(a2 > 0 && b2 > 0 && outsideEllipse(u[i], d2u[i], math.Sqrt(a2), math.Sqrt(b2), sin, cos))
+			if spike {
+				spikes[i] = true
+				found = true
+			}
+		}
+
+		if !found {
+			break
+		}
+	}
+
+	return spikes
+}
+
+// outsideEllipse will check if the point is outside the ellipse with the axes a and b
+// rotated by the angle with the sin and cos.  If sin and cos are 0, the ellipse is not rotated.
+func outsideEllipse(x float64, y float64, a float64, b float64, sin float64, cos float64) bool {
+	if a == 0 || b == 0 {
+		return false
+	}
+
+	if sin != 0 || cos != 0 {
+		x, y = x*cos+y*sin, -x*sin+y*cos
+	}
+
+	return (x*x)/(a*a)+(y*y)/(b*b) > 1.0
+}
+
+// replaceSpikes will replace the spikes in the series.  The spikes that can not
+// be replaced are set to the bad value.  The number of spikes replaced is returned.
+func replaceSpikes(series []float32, spikes []bool, replacement SpikeReplacement, bad float32) int {
+	count := countTrue(spikes)
+	if count == 0 {
+		return 0
+	}
+
+	switch replacement {
+	case ReplaceInterpolate:
+		missing := make([]bool, len(series))
+		for i, v := range series {
+			missing[i] = IsBad(v)
+		}
+		interpolateMissing(series, missing, spikes)
+
+		// Spikes at the ends can not be interpolated
+		for i, spike := range spikes {
+			if spike && isSpikeAtEnd(missing, spikes, i) {
+				series[i] = bad
+			}
+		}
+	case ReplaceLastGood:
+		last := bad
+		for i, v := range series {
+			if spikes[i] {
+				series[i] = last
+			} else if !IsBad(v) {
+				last = v
+			}
+		}
+	case ReplaceNaN:
+		nan := float32(math.NaN())
+		for i, spike := range spikes {
+			if spike {
+				series[i] = nan
+			}
+		}
+	default:
+		for i, spike := range spikes {
+			if spike {
+				series[i] = bad
+			}
+		}
+	}
+
+	return count
+}
+
+// interpolateMissing will linearly interpolate the values that are bad or spikes
+// from the good values around them.  Values before the first good value or after
+// the last good value are set to the nearest good value.
+func interpolateMissing(series []float32, bad []bool, spikes []bool) {
+	prev := -1
+	for i := 0; i <= len(series); i++ {
+		if i < len(series) && (bad[i] || spikes[i]) {
+			continue
+		}
+
+		// Fill from prev to i
+		for j := prev + 1; j < i; j++ {
+			switch {
+			case prev >= 0 && i < len(series):
+				frac := float64(j-prev) / float64(i-prev)
+				series[j] = float32(float64(series[prev]) + frac*(float64(series[i])-float64(series[prev])))
+			case prev >= 0:
+				series[j] = series[prev]
+			case i < len(series):
+				series[j] = series[i]
+			}
+		}
+		prev = i
+	}
+}
+
+// isSpikeAtEnd will check if there is no good value before or after the spike.
+func isSpikeAtEnd(bad []bool, spikes []bool, i int) bool {
+	before, after := false, false
+	for j := 0; j < i; j++ {
+		if !bad[j] && !spikes[j] {
+			before = true
+			break
+		}
+	}
+	for j := i + 1; j < len(bad); j++ {
+		if !bad[j] && !spikes[j] {
+			after = true
+			break
+		}
+	}
+
+	return !before || !after
+}
+
+// gradient will compute the central difference of the values.
+func gradient(values []float64) []float64 {
+	n := len(values)
+	grad := make([]float64, n)
+	for i := 1; i < n-1; i++ {
+		grad[i] = (values[i+1] - values[i-1]) / 2.0
+	}
+
+	return grad
+}
+
+// stdDev will compute the standard deviation of the values.
+func stdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+// goodValues will get the good values of the series.
+func goodValues(series []float32) []float64 {
+	var good []float64
+	for _, v := range series {
+		if !IsBad(v) {
+			good = append(good, float64(v))
+		}
+	}
+
+	return good
+}
+
+// median will get the median of the values.
+// The values are sorted.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+
+	return (values[n/2-1] + values[n/2]) / 2.0
+}
+
+// countTrue will count the true values.
+func countTrue(values []bool) int {
+	count := 0
+	for _, v := range values {
+		if v {
+			count++
+		}
+	}
+
+	return count
+}
+
+// medianInterval will get the median time in seconds between the ensembles.
+func medianInterval(ensembles []Ensemble) float64 {
+	var intervals []float64
+	var prev time.Time
+	for i := range ensembles {
+		t, err := ensembles[i].GetEnsembleData().Time()
+		if err != nil {
+			continue
+		}
+
+		if !prev.IsZero() && t.After(prev) {
+			intervals = append(intervals, t.Sub(prev).Seconds())
+		}
+		prev = t
+	}
+
+	if len(intervals) == 0 {
+		return 0
+	}
+
+	return median(intervals)
+}
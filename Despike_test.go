@@ -0,0 +1,158 @@
+package rti
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// spikeIndexes will get the index of each spike.
+func spikeIndexes(spikes []bool) []int {
+	var idx []int
+	for i, spike := range spikes {
+		if spike {
+			idx = append(idx, i)
+		}
+	}
+
+	return idx
+}
+
+// sameIndexes will check if the indexes are the same.
+func sameIndexes(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TestMADSpikes will check the MAD threshold.
+// The median is 1 and the MAD is 0.05, so the scaled MAD is 0.07413.
+func TestMADSpikes(t *testing.T) {
+	series := []float32{1, 1.1, 0.9, 1, 1.05, 0.95, 5, 1, BadVelocity}
+
+	tests := []struct {
+		threshold float64
+		want      []int
+	}{
+		{0, []int{6}},       // 3 scaled MADs is 0.2224
+		{1, []int{1, 2, 6}}, // 1 scaled MAD is 0.0741
+		{60, nil},           // 60 scaled MADs is 4.448
+		{53, []int{6}},      // 53 scaled MADs is 3.929
+	}
+
+	for _, test := range tests {
+		d := Despiker{Method: DespikeMAD, Threshold: test.threshold}
+		if got := spikeIndexes(d.madSpikes(series)); !sameIndexes(got, test.want) {
+			t.Errorf("threshold %g: spikes %v, want %v", test.threshold, got, test.want)
+		}
+	}
+}
+
+// TestAccelerationSpikes will check the acceleration limit and the step change.
+// The default limit is 1.5 g, 14.71 m/s^2.
+func TestAccelerationSpikes(t *testing.T) {
+	tests := []struct {
+		series []float32
+		dt     float64
+		limit  float64
+		want   []int
+	}{
+		{[]float32{0, 0, 0, 20, 0, 0}, 1, 0, []int{3}},                    // 20 m/s^2
+		{[]float32{0, 0, 0, 20, 0, 0}, 2, 0, nil},                         // 10 m/s^2
+		{[]float32{0, 0, 0, 12, 0, 0}, 1, 10, []int{3}},                   // Limit 10 m/s^2
+		{[]float32{0, 0, 20, BadVelocity, 0, 0}, 1, 0, []int{2}},          // Bad value skipped
+		{[]float32{0, 0, 0, 20, 20, 20, 0, 0}, 1, 0, []int{3, 4, 5}},      // 3 spikes in a row
+		{[]float32{0, 0, 0, 20, 20, 20, 20, 20, 20}, 1, 0, nil},           // Step change
+		{[]float32{0, 0, 20, 20, 20, 20, 20, 40, 20, 20}, 1, 0, []int{7}}, // Spike after a step change
+	}
+
+	for _, test := range tests {
+		d := Despiker{Method: DespikeAcceleration, AccelerationLimit: test.limit}
+		if got := spikeIndexes(d.accelerationSpikes(test.series, test.dt)); !sameIndexes(got, test.want) {
+			t.Errorf("%v dt %g: spikes %v, want %v", test.series, test.dt, got, test.want)
+		}
+	}
+}
+
+// TestAccelerationNoInterval will check the acceleration method needs the sample interval.
+func TestAccelerationNoInterval(t *testing.T) {
+	d := Despiker{Method: DespikeAcceleration}
+	if _, err := d.DespikeSeries([]float32{0, 20, 0}, 0); err == nil {
+		t.Error("DespikeSeries with no sample interval did not return an error")
+	}
+	if _, err := d.Despike([]Ensemble{{}, {}}); err == nil {
+		t.Error("Despike with no ensemble times did not return an error")
+	}
+}
+
+// TestPhaseSpaceSpikes will check a clean signal has no spikes and a single spike is found
+// and replaced close to the signal.
+func TestPhaseSpaceSpikes(t *testing.T) {
+	// Sinusoid with a little noise, a pure sinusoid has no spread in phase space
+	rng := rand.New(rand.NewSource(1))
+	series := make([]float32, 200)
+	for i := range series {
+		series[i] = float32(0.1*math.Sin(2*math.Pi*float64(i)/40) + 0.01*rng.NormFloat64())
+	}
+
+	d := Despiker{Method: DespikeGoringNikora, Replacement: ReplaceInterpolate}
+	if spikes := spikeIndexes(d.phaseSpaceSpikes(series)); len(spikes) != 0 {
+		t.Errorf("clean signal spikes = %v, want none", spikes)
+	}
+
+	want := (series[99] + series[101]) / 2
+	series[100] += 1
+
+	// The derivatives next to the spike contain the spike, so the neighbors are flagged too
+	spikes := spikeIndexes(d.phaseSpaceSpikes(series))
+	if !sameIndexes(spikes, []int{98, 99, 100, 101, 102}) {
+		t.Fatalf("spikes = %v, want the spike and its 2 neighbors on each side", spikes)
+	}
+
+	if _, err := d.DespikeSeries(series, 1); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(float64(series[100]-want)) > 0.02 {
+		t.Errorf("replaced spike = %g, want %g", series[100], want)
+	}
+}
+
+// TestReplaceSpikes will check each replacement.
+func TestReplaceSpikes(t *testing.T) {
+	nan := float32(math.NaN())
+	spikes := []bool{false, true, false, true}
+
+	tests := []struct {
+		replacement SpikeReplacement
+		bad         float32
+		want        []float32
+	}{
+		{ReplaceBad, BadVelocity, []float32{1, BadVelocity, 3, BadVelocity}},
+		{ReplaceBad, nan, []float32{1, nan, 3, nan}},
+		{ReplaceNaN, BadVelocity, []float32{1, nan, 3, nan}},
+		{ReplaceInterpolate, BadVelocity, []float32{1, 2, 3, BadVelocity}}, // Spike at the end is not interpolated
+		{ReplaceLastGood, BadVelocity, []float32{1, 1, 3, 3}},
+	}
+
+	for _, test := range tests {
+		series := []float32{1, 9, 3, 9}
+		if n := replaceSpikes(series, spikes, test.replacement, test.bad); n != 2 {
+			t.Errorf("replacement %d: %d spikes, want 2", test.replacement, n)
+		}
+
+		for i := range series {
+			got, want := series[i], test.want[i]
+			if math.IsNaN(float64(want)) != math.IsNaN(float64(got)) || (!math.IsNaN(float64(want)) && math.Abs(float64(got-want)) > 1e-6) {
+				t.Errorf("replacement %d: %v, want %v", test.replacement, series, test.want)
+				break
+			}
+		}
+	}
+}
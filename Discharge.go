@@ -0,0 +1,323 @@
+package rti
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ExtrapolationMethod is how the discharge is estimated in the unmeasured
+// top and bottom of the water column.
+type ExtrapolationMethod int
+
+// Extrapolation methods
+const (
+	ExtrapolatePower    ExtrapolationMethod = iota // Power law fit to the measured profile
+	ExtrapolateConstant                            // Constant value of the nearest measured bin
+)
+
+// EdgeShape is the shape of the unmeasured area between the bank and the first
+// or last ensemble.
+type EdgeShape int
+
+// Edge shapes
+const (
+	EdgeTriangular  EdgeShape = iota // Sloped bank
+	EdgeRectangular                  // Vertical wall
+)
+
+// Bank is the side of the river looking downstream.
+type Bank int
+
+// Banks
+const (
+	BankLeft  Bank = iota // Left bank looking downstream
+	BankRight             // Right bank looking downstream
+)
+
+// Edge coefficients for the edge discharge
+const edgeTriangular = 0.3535
+const edgeRectangular = 0.91
+
+// Defaults for the discharge
+const defaultPowerExponent = 1.0 / 6.0
+const defaultEdgeEnsembles = 10
+
+// Transect is a moving boat crossing of a river from one bank to the other.
+// The ensembles must be in time order and have Bottom Track or NMEA data for
+// the boat velocity.  Screen the ensembles before computing the discharge,
+// because every good bin is used.  The bins contaminated by the side lobe are
+// not used.
+type Transect struct {
+	Ensembles []Ensemble         // Ensembles in time order
+	Reference ReferenceProcessor // Priority of the boat velocity references
+
+	Top      ExtrapolationMethod // Top extrapolation
+	Bottom   ExtrapolationMethod // Bottom extrapolation
+	Exponent float64             // Power law exponent.  If 0, 1/6 is used
+
+	StartBank     Bank      // Bank at the start of the transect
+	LeftDistance  float64   // Distance from the left bank to the first or last ensemble in meters
+	RightDistance float64   // Distance from the right bank to the first or last ensemble in meters
+	LeftShape     EdgeShape // Shape of the left edge
+	RightShape    EdgeShape // Shape of the right edge
+	EdgeEnsembles int       // Number of ensembles averaged for each edge.  If 0, 10 is used
+
+	SideLobe SideLobeFilter // Side lobe cutoff settings
+}
+
+// Discharge is the discharge of a transect in m^3/s and its components.
+// The discharge is positive for flow downstream of the transect.
+type Discharge struct {
+	Top          float64 // Extrapolated discharge above the first good bin
+	Measured     float64 // Discharge in the good bins
+	Bottom       float64 // Extrapolated discharge below the last good bin
+	Left         float64 // Estimated discharge at the left edge
+	Right        float64 // Estimated discharge at the right edge
+	Total        float64 // Total discharge
+	NumEnsembles int     // Number of ensembles with a discharge
+}
+
+// ensembleDischarge is the discharge of a single ensemble.
+type ensembleDischarge struct {
+	top, measured, bottom float64 // Discharge in m^3
+	depth                 float64 // Water depth in meters
+	east, north           float64 // Depth averaged water velocity in m/s
+}
+
+// Discharge will compute the discharge of the transect.  The discharge of each
+// ensemble is the cross product of the water velocity and the boat velocity
+// integrated over the depth and the time of the ensemble.  The sign is set by
+// the start bank.
+// Q = (Water East * Boat North - Water North * Boat East) * dz * dt
+func (tr *Transect) Discharge() (Discharge, error) {
+	var result Discharge
+	var ensQ []ensembleDischarge
+
+	proc := tr.Reference
+	proc.prevPosition = nil
+
+	var prevTime time.Time
+	for i := range tr.Ensembles {
+		ens := &tr.Ensembles[i]
+		t, err := ens.GetEnsembleData().Time()
+		if err != nil {
+			continue
+		}
+
+		// Time of the ensemble from the previous good ensemble, so the time of the
+		// invalid ensembles in between is included in this ensemble
+		// The first ensemble uses the time to the next ensemble
+		var dt float64
+		if !prevTime.IsZero() {
+			dt = t.Sub(prevTime).Seconds()
+		} else if i+1 < len(tr.Ensembles) {
+			if next, err := tr.Ensembles[i+1].GetEnsembleData().Time(); err == nil {
+				dt = next.Sub(t).Seconds()
+			}
+		}
+
+		q, ok := tr.ensembleDischarge(ens, &proc, dt)
+		if !ok {
+			continue
+		}
+		prevTime = t
+
+		ensQ = append(ensQ, q)
+		result.Top += q.top
+		result.Measured += q.measured
+		result.Bottom += q.bottom
+	}
+
+	if len(ensQ) == 0 {
+		return result, errors.New("no ensembles with a discharge")
+	}
+	result.NumEnsembles = len(ensQ)
+
+	// Edges
+	sign := 1.0
+	if result.Top+result.Measured+result.Bottom < 0 {
+		sign = -1.0
+	}
+
+	numEdge := tr.EdgeEnsembles
+	if numEdge <= 0 {
+		numEdge = defaultEdgeEnsembles
+	}
+	if numEdge > len(ensQ) {
+		numEdge = len(ensQ)
+	}
+
+	start := sign * edgeDischarge(ensQ[:numEdge])
+	end := sign * edgeDischarge(ensQ[len(ensQ)-numEdge:])
+	if tr.StartBank == BankLeft {
+		result.Left = start * edgeCoefficient(tr.LeftShape) * tr.LeftDistance
+		result.Right = end * edgeCoefficient(tr.RightShape) * tr.RightDistance
+	} else {
+		result.Right = start * edgeCoefficient(tr.RightShape) * tr.RightDistance
+		result.Left = end * edgeCoefficient(tr.LeftShape) * tr.LeftDistance
+	}
+
+	result.Total = result.Top + result.Measured + result.Bottom + result.Left + result.Right
+
+	return result, nil
+}
+
+// ensembleDischarge will compute the discharge of the ensemble over the time dt in seconds.
+func (tr *Transect) ensembleDischarge(ens *Ensemble, proc *ReferenceProcessor, dt float64) (ensembleDischarge, bool) {
+	var q ensembleDischarge
+
+	// Boat velocity is the opposite of the reference
+	ref, refEast, refNorth, _ := proc.reference(ens)
+	if ref == ReferenceNone || dt <= 0 {
+		return q, false
+	}
+	boatEast, boatNorth := -refEast, -refNorth
+
+	// Water depth
+	anc := ens.GetAncillaryData()
+	btRange, ok := ens.GetBottomTrackData().AverageRange()
+	if !ok || anc.BinSize <= 0 {
+		return q, false
+	}
+	draft := float64(anc.TransducerDepth)
+	q.depth = draft + float64(btRange)
+
+	cutoff, ok := tr.SideLobe.cutoff(ens)
+	if !ok {
+		cutoff = math.Inf(1)
+	}
+
+	// Measured bins
+	earth := ens.GetEarthVelocityData()
+	if earth.Velocity.NumBeams <= EarthNorth {
+		return q, false
+	}
+	binSize := float64(anc.BinSize)
+
+	// Cross product is positive downstream when starting at the right bank
+	direction := 1.0
+	if tr.StartBank == BankLeft {
+		direction = -1.0
+	}
+
+	// Cross product of each bin above the side lobe cutoff
+	// Bad bins are NaN
+	var xs []float64
+	count := 0
+	for bin := 0; bin < earth.Velocity.NumBins; bin++ {
+		binRange := float64(anc.FirstBinRange) + float64(bin)*binSize
+		if binRange > cutoff {
+			break
+		}
+
+		east := earth.Velocity.At(bin, EarthEast)
+		north := earth.Velocity.At(bin, EarthNorth)
+		if IsBad(east) || IsBad(north) {
+			xs = append(xs, math.NaN())
+			continue
+		}
+
+		// Water velocity relative to the earth
+		waterEast, waterNorth := float64(east), float64(north)
+		if earth.Reference == ReferenceNone {
+			waterEast -= refEast
+			waterNorth -= refNorth
+		}
+
+		xs = append(xs, direction*(waterEast*boatNorth-waterNorth*boatEast))
+		q.east += waterEast
+		q.north += waterNorth
+		count++
+	}
+
+	if count == 0 {
+		return q, false
+	}
+	q.east /= float64(count)
+	q.north /= float64(count)
+
+	// Measured area from the first to the last good bin
+	// The bad bins in between are interpolated from the good bins around them
+	first, last := -1, -1
+	for bin, x := range xs {
+		if !math.IsNaN(x) {
+			if first < 0 {
+				first = bin
+			}
+			last = bin
+		}
+	}
+	prev := first
+	for bin := first; bin <= last; bin++ {
+		if math.IsNaN(xs[bin]) {
+			next := bin + 1
+			for math.IsNaN(xs[next]) {
+				next++
+			}
+			frac := float64(bin-prev) / float64(next-prev)
+			xs[bin] = xs[prev] + frac*(xs[next]-xs[prev])
+		} else {
+			prev = bin
+		}
+		q.measured += xs[bin] * binSize
+	}
+
+	topDepth := draft + float64(anc.FirstBinRange) + float64(first)*binSize
+	bottomDepth := draft + float64(anc.FirstBinRange) + float64(last)*binSize
+	topX, bottomX := xs[first], xs[last]
+
+	// Height above the bottom of the measured area
+	zTop := math.Min(q.depth, q.depth-(topDepth-binSize/2))
+	zBottom := math.Max(0, q.depth-(bottomDepth+binSize/2))
+
+	// Power law fit to the measured area
+	b := tr.Exponent
+	if b == 0 {
+		b = defaultPowerExponent
+	}
+	var a float64
+	if area := (math.Pow(zTop, b+1) - math.Pow(zBottom, b+1)) / (b + 1); area > 0 {
+		a = q.measured / area
+	}
+
+	if tr.Top == ExtrapolateConstant {
+		q.top = topX * (q.depth - zTop)
+	} else {
+		q.top = a / (b + 1) * (math.Pow(q.depth, b+1) - math.Pow(zTop, b+1))
+	}
+
+	if tr.Bottom == ExtrapolateConstant {
+		q.bottom = bottomX * zBottom
+	} else {
+		q.bottom = a / (b + 1) * math.Pow(zBottom, b+1)
+	}
+
+	q.top *= dt
+	q.measured *= dt
+	q.bottom *= dt
+
+	return q, true
+}
+
+// edgeDischarge will get the mean water speed times the mean depth of the edge ensembles.
+func edgeDischarge(ensQ []ensembleDischarge) float64 {
+	var east, north, depth float64
+	for _, q := range ensQ {
+		east += q.east
+		north += q.north
+		depth += q.depth
+	}
+	n := float64(len(ensQ))
+
+	return math.Hypot(east/n, north/n) * depth / n
+}
+
+// edgeCoefficient will get the coefficient for the edge shape.
+func edgeCoefficient(shape EdgeShape) float64 {
+	if shape == EdgeRectangular {
+		return edgeRectangular
+	}
+
+	return edgeTriangular
+}
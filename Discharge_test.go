@@ -0,0 +1,152 @@
+package rti
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// newDischargeEnsemble will create an ensemble 5 m deep with 1 m bins starting
+// at 1 m for a boat moving over an East current.  The Earth and Bottom Track
+// velocities are relative to the ADCP like an RTI ADCP measures them.  A NaN
+// water velocity is a bad bin.  With a 20 degree beam angle, the side lobe
+// cutoff is 3.7 m, so only the first 3 bins are used.
+func newDischargeEnsemble(t time.Time, waterEast []float64, boatEast, boatNorth float64) Ensemble {
+	var ens Ensemble
+	setEnsembleTime(&ens.EnsembleData, t)
+
+	ens.AncillaryData.BinSize = 1
+	ens.AncillaryData.FirstBinRange = 1
+
+	ens.EarthVelocityData.Base.ElementMultiplier = numVelocityComponents
+	ens.EarthVelocityData.Velocity = NewBinBeamMatrix[float32](len(waterEast), numVelocityComponents)
+	for bin, east := range waterEast {
+		if math.IsNaN(east) {
+			ens.EarthVelocityData.Velocity.Set(bin, EarthEast, BadVelocity)
+			ens.EarthVelocityData.Velocity.Set(bin, EarthNorth, BadVelocity)
+			continue
+		}
+		ens.EarthVelocityData.Velocity.Set(bin, EarthEast, float32(east-boatEast))
+		ens.EarthVelocityData.Velocity.Set(bin, EarthNorth, float32(-boatNorth))
+	}
+
+	ens.BottomTrackData.Range = []float32{5, 5, 5, 5}
+	ens.BottomTrackData.EarthVelocity = []float32{float32(-boatEast), float32(-boatNorth), 0, 0}
+
+	return ens
+}
+
+// newDischargeTransect will create a transect of 2 ensembles 1 second apart.
+func newDischargeTransect(start Bank, waterEast []float64, boatNorth float64) Transect {
+	t := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	return Transect{
+		Ensembles: []Ensemble{
+			newDischargeEnsemble(t, waterEast, 0, boatNorth),
+			newDischargeEnsemble(t.Add(time.Second), waterEast, 0, boatNorth),
+		},
+		Reference: ReferenceProcessor{Priority: []VelocityReference{ReferenceBottomTrack}},
+		Top:       ExtrapolateConstant,
+		Bottom:    ExtrapolateConstant,
+		StartBank: start,
+		SideLobe:  SideLobeFilter{BeamAngle: 20},
+	}
+}
+
+// TestDischargeSign will check the discharge is positive downstream for each
+// start bank.  The current is 1 m/s East, so the left bank is North.
+// Each ensemble is 1 m/s * 5 m * 1 s = 5 m^3.
+func TestDischargeSign(t *testing.T) {
+	tests := []struct {
+		start     Bank
+		boatNorth float64
+		want      float64
+	}{
+		{BankRight, 1, 10},   // Right bank to left bank
+		{BankLeft, -1, 10},   // Left bank to right bank
+		{BankRight, -1, -10}, // Moving the wrong way for the start bank
+		{BankLeft, 1, -10},
+	}
+
+	for _, test := range tests {
+		tr := newDischargeTransect(test.start, []float64{1, 1, 1, 100}, test.boatNorth)
+		q, err := tr.Discharge()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if q.NumEnsembles != 2 || math.Abs(q.Total-test.want) > 1e-6 || math.Abs(q.Measured-test.want*3/5) > 1e-6 {
+			t.Errorf("start bank %d boat north %g: total %g measured %g, want %g %g", test.start, test.boatNorth, q.Total, q.Measured, test.want, test.want*3/5)
+		}
+	}
+}
+
+// TestEnsembleDischarge will check the top and bottom extrapolation and the
+// interpolation of a bad bin.  The measured area is from 0.5 m to 3.5 m deep,
+// so the top is 0.5 m and the bottom is 1.5 m.
+func TestEnsembleDischarge(t *testing.T) {
+	nan := math.NaN()
+
+	tests := []struct {
+		method                ExtrapolationMethod
+		waterEast             []float64
+		top, measured, bottom float64
+	}{
+		{ExtrapolateConstant, []float64{1, 1, 1, 100}, 0.5, 3, 1.5},
+		{ExtrapolatePower, []float64{1, 1, 1, 100}, 0.543138, 3, 1.152600},
+		{ExtrapolateConstant, []float64{1, nan, 3, 100}, 0.5, 6, 4.5}, // Bad bin is 2
+		{ExtrapolatePower, []float64{1, nan, 3, 100}, 1.086276, 6, 2.305200},
+	}
+
+	for _, test := range tests {
+		tr := Transect{Top: test.method, Bottom: test.method, StartBank: BankRight, SideLobe: SideLobeFilter{BeamAngle: 20}}
+		ens := newDischargeEnsemble(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), test.waterEast, 0, 1)
+		proc := ReferenceProcessor{Priority: []VelocityReference{ReferenceBottomTrack}}
+
+		q, ok := tr.ensembleDischarge(&ens, &proc, 1)
+		if !ok {
+			t.Fatalf("%v: no discharge", test.waterEast)
+		}
+
+		if math.Abs(q.top-test.top) > 1e-6 || math.Abs(q.measured-test.measured) > 1e-6 || math.Abs(q.bottom-test.bottom) > 1e-6 {
+			t.Errorf("method %d %v: top %g measured %g bottom %g, want %g %g %g", test.method, test.waterEast, q.top, q.measured, q.bottom, test.top, test.measured, test.bottom)
+		}
+	}
+}
+
+// TestDischargeEdges will check the edge discharge with each edge shape.
+// Edge = Coefficient * Speed * Depth * Distance, with a 1 m/s speed, 5 m depth,
+// 2 m to the left bank and 4 m to the right bank.
+func TestDischargeEdges(t *testing.T) {
+	tests := []struct {
+		start                 Bank
+		leftShape, rightShape EdgeShape
+		left, right           float64
+	}{
+		{BankRight, EdgeTriangular, EdgeRectangular, 0.3535 * 5 * 2, 0.91 * 5 * 4},
+		{BankRight, EdgeRectangular, EdgeTriangular, 0.91 * 5 * 2, 0.3535 * 5 * 4},
+		{BankLeft, EdgeTriangular, EdgeRectangular, 0.3535 * 5 * 2, 0.91 * 5 * 4},
+	}
+
+	for _, test := range tests {
+		boatNorth := 1.0
+		if test.start == BankLeft {
+			boatNorth = -1
+		}
+		tr := newDischargeTransect(test.start, []float64{1, 1, 1, 100}, boatNorth)
+		tr.LeftDistance, tr.RightDistance = 2, 4
+		tr.LeftShape, tr.RightShape = test.leftShape, test.rightShape
+
+		q, err := tr.Discharge()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if math.Abs(q.Left-test.left) > 1e-6 || math.Abs(q.Right-test.right) > 1e-6 {
+			t.Errorf("start bank %d shapes %d %d: left %g right %g, want %g %g", test.start, test.leftShape, test.rightShape, q.Left, q.Right, test.left, test.right)
+		}
+		if math.Abs(q.Total-(10+test.left+test.right)) > 1e-6 {
+			t.Errorf("start bank %d: total %g, want %g", test.start, q.Total, 10+test.left+test.right)
+		}
+	}
+}
@@ -1,9 +1,6 @@
 package rti
 
-import (
-	"encoding/binary"
-	"math"
-)
+import "math"
 
 // EarthVelocityDataSet will contain all the Earth Velocity Data set values.
 // These values describe water profile data in m/s.
@@ -11,68 +8,135 @@ import (
 // base data set.
 // Bin x Beam
 type EarthVelocityDataSet struct {
-	Base     BaseDataSet      // Base Dataset
-	Velocity [][]float32      // Velcity data in m/s
-	Vectors  []VelocityVector // Velocity vector with maginitude and direction
+	Base     BaseDataSet            // Base Dataset
+	Velocity BinBeamMatrix[float32] // Velcity data in m/s
+	Vectors  []VelocityVector       // Velocity vector with maginitude and direction
+
+	Declination          float32           // Magnetic declination in degrees applied to the velocities
+	DeclinationFromModel bool              // Flag if the declination is from the magnetic model, otherwise the fixed declination
+	HeadingOffset        float32           // Heading offset in degrees applied to the velocities
+	Reference            VelocityReference // Reference used to remove the boat motion from the velocities
 }
 
 // Decode will take the binary data and decode into
 // into the ensemble data set.
 func (vel *EarthVelocityDataSet) Decode(data []byte) {
 
-	// Initialize the 2D array
-	// [Bins][Beams]
-	vel.Velocity = make([][]float32, vel.Base.NumElements)
-	for i := range vel.Velocity {
-		vel.Velocity[i] = make([]float32, vel.Base.ElementMultiplier)
+	// Decode the [Bins][Beams] data
+	vel.Velocity = decodeBinBeamMatrix[float32](&vel.Base, data)
+
+	// Convert the bad values to NaN
+	if vel.Base.badToNaN {
+		badToNaN(vel.Velocity)
+	}
+
+	// Apply the magnetic declination and heading offset
+	vel.rotate(vel.Declination + vel.HeadingOffset)
+
+	// Calculate the velocity vector for each bin
+	vel.calcVectors()
+}
+
+// Rotate will rotate the East and North velocities by the magnetic declination
+// and heading offset in degrees.  The values are added to the declination and
+// heading offset stored in the dataset, so the total rotation applied to the
+// velocities is always known.  The velocity vectors are recalculated.
+func (vel *EarthVelocityDataSet) Rotate(declination float32, headingOffset float32) {
+	vel.Declination += declination
+	vel.HeadingOffset += headingOffset
+
+	vel.rotate(declination + headingOffset)
+	vel.calcVectors()
+}
+
+// addDeclination will add the magnetic declination in degrees.  If the
+// dataset is waiting to be decoded, the declination is applied when decoded.
+func (vel *EarthVelocityDataSet) addDeclination(declination float32) {
+	if declination == 0 {
+		return
+	}
+
+	if vel.Base.IsPending() {
+		vel.Declination += declination
+		return
 	}
-	vel.Vectors = make([]VelocityVector, vel.Base.NumElements)
 
-	// Not enough data
-	if uint32(len(data)) < vel.Base.NumElements*vel.Base.ElementMultiplier*uint32(BytesInFloat) {
+	vel.Rotate(declination, 0)
+}
+
+// rotate will rotate the East and North velocities clockwise by the angle in degrees.
+func (vel *EarthVelocityDataSet) rotate(angle float32) {
+	if angle == 0 || vel.Velocity.NumBeams <= EarthNorth {
 		return
 	}
 
-	// Set each beam and bin data
-	ptr := 0
-	for beam := 0; beam < int(vel.Base.ElementMultiplier); beam++ {
-		for bin := 0; bin < int(vel.Base.NumElements); bin++ {
-			// Get the location of the data
-			ptr = GetBinBeamIndex(int(vel.Base.NameLen), int(vel.Base.NumElements), beam, bin)
+	sin, cos := math.Sincos(float64(angle) * (math.Pi / 180.0))
 
-			// Set the data to float
-			bits := binary.LittleEndian.Uint32(data[ptr : ptr+BytesInFloat])
-			vel.Velocity[bin][beam] = math.Float32frombits(bits)
+	for bin := 0; bin < vel.Velocity.NumBins; bin++ {
+		east := vel.Velocity.At(bin, EarthEast)
+		north := vel.Velocity.At(bin, EarthNorth)
 
-			// Calculate the velocity vector
-			vel.Vectors[bin] = calcVV(vel.Velocity[bin])
+		// Bad values are not rotated
+		if IsBad(east) || IsBad(north) {
+			continue
 		}
+
+		vel.Velocity.Set(bin, EarthEast, float32(float64(east)*cos+float64(north)*sin))
+		vel.Velocity.Set(bin, EarthNorth, float32(-float64(east)*sin+float64(north)*cos))
+	}
+}
+
+// calcVectors will calculate the velocity vector for each bin.
+// A vertical beam has no East and North velocity
+// so no velocity vector can be calculated.
+func (vel *EarthVelocityDataSet) calcVectors() {
+	if vel.IsVerticalBeam() {
+		vel.Vectors = nil
+		return
 	}
+
+	bad := vel.Base.badValue()
+	vel.Vectors = make([]VelocityVector, vel.Velocity.NumBins)
+	for bin := range vel.Vectors {
+		vel.Vectors[bin] = calcVV(vel.Velocity.Bin(bin), bad)
+	}
+}
+
+// IsVerticalBeam will check if the dataset contains a single vertical beam.
+// A vertical beam only has a vertical velocity, so no velocity vectors are calculated.
+func (vel *EarthVelocityDataSet) IsVerticalBeam() bool {
+	return vel.Base.ElementMultiplier == 1
 }
 
 // calcVV will calculate the velocity vector for each bin.
-func calcVV(binData []float32) VelocityVector {
+// The magnitude is the horizontal water speed and the
+// direction is the compass direction the water is moving to.
+// If the bin is bad, all the values are set to the bad value.
+func calcVV(binData []float32, bad float32) VelocityVector {
 	// Init the values
-	var vv = VelocityVector{Magnitude: BadVelocity, DirectionXNorth: BadVelocity, DirectionYNorth: BadVelocity}
+	b := float64(bad)
+	var vv = VelocityVector{Magnitude: b, DirectionXNorth: b, DirectionYNorth: b}
 
-	// Need East, North, Vert
-	if len(binData) < 3 {
+	// Need East, North
+	if len(binData) < 2 {
 		return vv
 	}
 
 	// All the data must be good
-	if binData[0] == BadVelocity || binData[1] == BadVelocity || binData[2] == BadVelocity {
+	if IsBad(binData[EarthEast]) || IsBad(binData[EarthNorth]) {
 		return vv
 	}
 
+	east := float64(binData[EarthEast])
+	north := float64(binData[EarthNorth])
+
 	// Magnitude
-	// Sqrt(East^2 + North^2 + Vert^2)
-	mag := math.Sqrt(math.Pow(float64(binData[0]), 2) + math.Pow(float64(binData[1]), 2) + math.Pow(float64(binData[2]), 2))
-	vv.Magnitude = math.Abs(mag)
+	// Sqrt(East^2 + North^2)
+	vv.Magnitude = math.Hypot(east, north)
 
 	// Direct X North
 	// atan2(east, north)
-	dirXNorth := math.Atan2(float64(binData[0]), float64(binData[1])) * (180.0 / math.Pi)
+	dirXNorth := math.Atan2(east, north) * (180.0 / math.Pi)
 	if dirXNorth < 0.0 {
 		dirXNorth = 360.0 + dirXNorth
 	}
@@ -80,7 +144,7 @@ func calcVV(binData []float32) VelocityVector {
 
 	// Direct Y North
 	// atan2(north, east)
-	dirYNorth := math.Atan2(float64(binData[1]), float64(binData[2])) * (180.0 / math.Pi)
+	dirYNorth := math.Atan2(north, east) * (180.0 / math.Pi)
 	if dirYNorth < 0.0 {
 		dirYNorth = 360.0 + dirYNorth
 	}
@@ -88,3 +152,9 @@ func calcVV(binData []float32) VelocityVector {
 
 	return vv
 }
+
+// GoodMask will create a mask of the good values.
+// The mask is true for each good value and false for each bad value.
+func (vel *EarthVelocityDataSet) GoodMask() BinBeamMatrix[bool] {
+	return GoodMask(vel.Velocity)
+}
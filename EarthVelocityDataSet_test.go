@@ -0,0 +1,50 @@
+package rti
+
+import (
+	"math"
+	"testing"
+)
+
+// TestCalcVV will check the velocity vector and the bad value of a bad bin.
+func TestCalcVV(t *testing.T) {
+	tests := []struct {
+		bin               []float32
+		bad               float32
+		magnitude, xNorth float64
+		yNorth            float64
+	}{
+		{[]float32{0, 1, 0, 0}, BadVelocity, 1, 0, 90},
+		{[]float32{1, 0, 0, 0}, BadVelocity, 1, 90, 0},
+		{[]float32{-3, -4, 0, 0}, BadVelocity, 5, 216.8699, 233.1301},
+		{[]float32{BadVelocity, 1, 0, 0}, BadVelocity, BadVelocity, BadVelocity, BadVelocity},
+		{[]float32{float32(math.NaN()), 1, 0, 0}, float32(math.NaN()), math.NaN(), math.NaN(), math.NaN()},
+	}
+
+	for _, test := range tests {
+		vv := calcVV(test.bin, test.bad)
+		got := []float64{vv.Magnitude, vv.DirectionXNorth, vv.DirectionYNorth}
+		want := []float64{test.magnitude, test.xNorth, test.yNorth}
+		for i := range got {
+			if math.IsNaN(want[i]) != math.IsNaN(got[i]) || math.Abs(got[i]-want[i]) > 1e-3 {
+				t.Errorf("calcVV(%v) = %v, want %v", test.bin, got, want)
+				break
+			}
+		}
+	}
+}
+
+// TestCalcVectorsBadToNaN will check the vectors of a NaN dataset use NaN.
+func TestCalcVectorsBadToNaN(t *testing.T) {
+	vel := EarthVelocityDataSet{Velocity: NewBinBeamMatrix[float32](2, 4)}
+	vel.Base.ElementMultiplier = 4
+	vel.Base.badToNaN = true
+	vel.Velocity.Set(0, EarthEast, float32(math.NaN()))
+
+	vel.calcVectors()
+	if !math.IsNaN(vel.Vectors[0].Magnitude) {
+		t.Errorf("bad bin magnitude = %g, want NaN", vel.Vectors[0].Magnitude)
+	}
+	if vel.Vectors[1].Magnitude != 0 {
+		t.Errorf("good bin magnitude = %g, want 0", vel.Vectors[1].Magnitude)
+	}
+}
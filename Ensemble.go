@@ -1,7 +1,12 @@
 package rti
 
+import (
+	"math"
+	"time"
+)
+
 // MaxNumDataSets is the number number of datasets.
-const MaxNumDataSets = 12
+const MaxNumDataSets = 20
 
 // BytesInInt32 is the number of bytes in Int32
 const BytesInInt32 = 4
@@ -12,8 +17,40 @@ const BytesInFloat = 4
 // BytesInInt8 is the number of bytes in Int8
 const BytesInInt8 = 1
 
-// BAD_VELOCITY is the value for a bad value.
-const BadVelocity = 88.88
+// BadVelocity is the value RTI instruments use to flag a bad value.
+const BadVelocity = 88.888
+
+// badValueTolerance is the tolerance to compare a value with BadVelocity.
+// The value is decoded from a float, so it is not compared exactly.
+const badValueTolerance = 0.0005
+
+// IsBad will check if the value is flagged bad.  A value is bad if it is
+// BadVelocity within a small tolerance or NaN.
+func IsBad(value float32) bool {
+	v := float64(value)
+	return math.IsNaN(v) || math.Abs(v-BadVelocity) < badValueTolerance
+}
+
+// GoodMask will create a mask of the good values in the matrix.
+// The mask is true for each good value and false for each bad value.
+func GoodMask(mat BinBeamMatrix[float32]) BinBeamMatrix[bool] {
+	mask := NewBinBeamMatrix[bool](mat.NumBins, mat.NumBeams)
+	for i, v := range mat.Data {
+		mask.Data[i] = !IsBad(v)
+	}
+
+	return mask
+}
+
+// badToNaN will replace all the bad values in the matrix with NaN.
+func badToNaN(mat BinBeamMatrix[float32]) {
+	nan := float32(math.NaN())
+	for i, v := range mat.Data {
+		if IsBad(v) {
+			mat.Data[i] = nan
+		}
+	}
+}
 
 // VelocityVector holds the water maginitude and direction.
 type VelocityVector struct {
@@ -32,6 +69,45 @@ type BaseDataSet struct {
 	Imag              uint32
 	NameLen           uint32
 	Name              string
+	raw               []byte // Binary data waiting to be decoded
+	badToNaN          bool   // Convert bad values to NaN when decoded
+}
+
+// badValue will get the value used to flag a bad value in the dataset.
+// It is NaN if the bad values are converted to NaN, otherwise BadVelocity.
+func (base *BaseDataSet) badValue() float32 {
+	if base.badToNaN {
+		return float32(math.NaN())
+	}
+
+	return BadVelocity
+}
+
+// badLike will get the bad value with the same convention as the value v.
+// It is NaN if v is NaN, otherwise BadVelocity.
+func badLike(v float32) float32 {
+	if math.IsNaN(float64(v)) {
+		return v
+	}
+
+	return BadVelocity
+}
+
+// IsPending will check if the dataset is waiting to be decoded.
+func (base *BaseDataSet) IsPending() bool {
+	return base.raw != nil
+}
+
+// decodePending will decode the dataset if the binary data was kept
+// to be decoded on first access.
+func (base *BaseDataSet) decodePending(decode func([]byte)) {
+	if base.raw == nil {
+		return
+	}
+
+	data := base.raw
+	base.raw = nil
+	decode(data)
 }
 
 // Ensemble is the container for the ADCP data.
@@ -46,4 +122,136 @@ type Ensemble struct {
 	EarthVelocityData      EarthVelocityDataSet      // Earth Velocity Data Set
 	AmplitudeData          AmplitudeDataSet          // Amplitude Data Set
 	CorrelationData        CorrelationDataSet        // Correlation Data Set
+	GoodBeamData           GoodBeamDataSet           // Good Beam Data Set
+	GoodEarthData          GoodEarthDataSet          // Good Earth Data Set
+	BottomTrackData        BottomTrackDataSet        // Bottom Track Data Set
+	NmeaData               NmeaDataSet               // NMEA Data Set
+	VerticalBeamData       *VerticalBeam             // Vertical Beam merged from a vertical beam ensemble
+
+	ScreenReasons []ScreenReason // Reason each bin was marked bad by the screening filters
+	Average       *AverageInfo   // Ensembles used if this is an averaged ensemble
+	Fill          FillType       // How the ensemble was created if it was resampled to a time grid
+
+	declinationOpts *DecodeOptions // Options to compute the declination when the Earth velocities are decoded
+}
+
+// GetEnsembleData will get the Ensemble Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetEnsembleData() *EnsembleDataSet {
+	ens.EnsembleData.Base.decodePending(ens.EnsembleData.Decode)
+	return &ens.EnsembleData
+}
+
+// GetAncillaryData will get the Ancillary Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetAncillaryData() *AncillaryDataSet {
+	ens.AncillaryData.Base.decodePending(ens.AncillaryData.Decode)
+	return &ens.AncillaryData
+}
+
+// GetBeamVelocityData will get the Beam Velocity Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetBeamVelocityData() *BeamVelocityDataSet {
+	ens.BeamVelocityData.Base.decodePending(ens.BeamVelocityData.Decode)
+	return &ens.BeamVelocityData
+}
+
+// GetInstrumentVelocityData will get the Instrument Velocity Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetInstrumentVelocityData() *InstrumentVelocityDataSet {
+	ens.InstrumentVelocityData.Base.decodePending(ens.InstrumentVelocityData.Decode)
+	return &ens.InstrumentVelocityData
+}
+
+// GetEarthVelocityData will get the Earth Velocity Data Set.
+// If the dataset has not been decoded yet, it is decoded now.  The
+// magnetic declination from the model is computed before it is decoded.
+func (ens *Ensemble) GetEarthVelocityData() *EarthVelocityDataSet {
+	if opts := ens.declinationOpts; opts != nil && ens.EarthVelocityData.Base.IsPending() {
+		ens.declinationOpts = nil
+		opts.applyDeclination(ens)
+	}
+	ens.EarthVelocityData.Base.decodePending(ens.EarthVelocityData.Decode)
+	return &ens.EarthVelocityData
+}
+
+// GetAmplitudeData will get the Amplitude Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetAmplitudeData() *AmplitudeDataSet {
+	ens.AmplitudeData.Base.decodePending(ens.AmplitudeData.Decode)
+	return &ens.AmplitudeData
+}
+
+// GetCorrelationData will get the Correlation Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetCorrelationData() *CorrelationDataSet {
+	ens.CorrelationData.Base.decodePending(ens.CorrelationData.Decode)
+	return &ens.CorrelationData
+}
+
+// GetGoodBeamData will get the Good Beam Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetGoodBeamData() *GoodBeamDataSet {
+	ens.GoodBeamData.Base.decodePending(ens.GoodBeamData.Decode)
+	return &ens.GoodBeamData
+}
+
+// GetGoodEarthData will get the Good Earth Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetGoodEarthData() *GoodEarthDataSet {
+	ens.GoodEarthData.Base.decodePending(ens.GoodEarthData.Decode)
+	return &ens.GoodEarthData
+}
+
+// GetBottomTrackData will get the Bottom Track Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetBottomTrackData() *BottomTrackDataSet {
+	ens.BottomTrackData.Base.decodePending(ens.BottomTrackData.Decode)
+	return &ens.BottomTrackData
+}
+
+// GetNmeaData will get the NMEA Data Set.
+// If the dataset has not been decoded yet, it is decoded now.
+func (ens *Ensemble) GetNmeaData() *NmeaDataSet {
+	ens.NmeaData.Base.decodePending(ens.NmeaData.Decode)
+	return &ens.NmeaData
+}
+
+// DecodeAll will decode all the datasets that are waiting to be decoded.
+func (ens *Ensemble) DecodeAll() {
+	ens.GetEnsembleData()
+	ens.GetAncillaryData()
+	ens.GetBeamVelocityData()
+	ens.GetInstrumentVelocityData()
+	ens.GetEarthVelocityData()
+	ens.GetAmplitudeData()
+	ens.GetCorrelationData()
+	ens.GetGoodBeamData()
+	ens.GetGoodEarthData()
+	ens.GetBottomTrackData()
+	ens.GetNmeaData()
+}
+
+// FirstPing will get the time of the first ping in the ensemble.
+// The first ping time in the Ancillary Data Set is in seconds
+// relative to the ensemble time.
+func (ens *Ensemble) FirstPing() (time.Time, error) {
+	return ens.pingTime(ens.GetAncillaryData().FirstPingTime)
+}
+
+// LastPing will get the time of the last ping in the ensemble.
+// The last ping time in the Ancillary Data Set is in seconds
+// relative to the ensemble time.
+func (ens *Ensemble) LastPing() (time.Time, error) {
+	return ens.pingTime(ens.GetAncillaryData().LastPingTime)
+}
+
+// pingTime will add the ping time in seconds to the ensemble time.
+func (ens *Ensemble) pingTime(seconds float32) (time.Time, error) {
+	t, err := ens.GetEnsembleData().Time()
+	if err != nil {
+		return t, err
+	}
+
+	return t.Add(time.Duration(float64(seconds) * float64(time.Second))), nil
 }
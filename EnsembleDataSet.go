@@ -1,6 +1,10 @@
 package rti
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
 
 // EnsembleDataSet will contain all the Ensemble Data set values.
 // These values describe ensemble with integer values.  This includes
@@ -12,7 +16,7 @@ type EnsembleDataSet struct {
 	NumBeams         uint32                 // Number of beams
 	DesiredPingCount uint32                 // Desired Number of pings
 	ActualPingCount  uint32                 // Actual Number of pings
-	Status           uint32                 // ADCP Status
+	Status           Status                 // ADCP Status
 	Year             uint32                 // Year
 	Month            uint32                 // Month
 	Day              uint32                 // Day
@@ -23,6 +27,7 @@ type EnsembleDataSet struct {
 	SerialNumber     SerialNumber           // Serial Number
 	Firmware         Firmware               // Firmware
 	SubsystemConfig  SubsystemConfiguration // Subsystem configuration
+	Location         *time.Location         // Instrument time zone.  If nil, UTC is used
 }
 
 // Decode will take the binary data and decode into
@@ -30,7 +35,7 @@ type EnsembleDataSet struct {
 func (ens *EnsembleDataSet) Decode(data []byte) {
 
 	// Not enough data
-	if len(data) < 13*BytesInInt32 {
+	if !hasElements(&ens.Base, data, 13) {
 		return
 	}
 
@@ -56,7 +61,7 @@ func (ens *EnsembleDataSet) Decode(data []byte) {
 
 	// Status
 	ptr = GenerateIndex(5, ens.Base.NameLen, ens.Base.Enstype)
-	ens.Status = binary.LittleEndian.Uint32(data[ptr : ptr+BytesInInt32])
+	ens.Status = Status(binary.LittleEndian.Uint32(data[ptr : ptr+BytesInInt32]))
 
 	// Year
 	ptr = GenerateIndex(6, ens.Base.NameLen, ens.Base.Enstype)
@@ -87,10 +92,17 @@ func (ens *EnsembleDataSet) Decode(data []byte) {
 	ens.HSec = binary.LittleEndian.Uint32(data[ptr : ptr+BytesInInt32])
 
 	// Ensure enough data is there to decode Firmware and serial number
-	if len(data) >= 22*BytesInInt32 {
+	// Older firmware has a shorter layout without these values
+	if hasElements(&ens.Base, data, 22) {
 		// Serial Number
 		ptr = GenerateIndex(13, ens.Base.NameLen, ens.Base.Enstype)
-		ens.SerialNumber.SerialNumber = string(data[ptr : ptr+(8*BytesInInt32)])
+		// If the serial number is not valid, only the string is kept
+		serial := string(data[ptr : ptr+(8*BytesInInt32)])
+		if sn, err := ParseSerialNumber(serial); err == nil {
+			ens.SerialNumber = sn
+		} else {
+			ens.SerialNumber = SerialNumber{SerialNumber: serial}
+		}
 
 		// Firmware
 		ptr = GenerateIndex(21, ens.Base.NameLen, ens.Base.Enstype)
@@ -98,8 +110,42 @@ func (ens *EnsembleDataSet) Decode(data []byte) {
 	}
 
 	// Ensure enough data to decode Subsystem configuration
-	if len(data) >= 23*BytesInInt32 {
+	// and the firmware includes the subsystem configuration
+	if hasElements(&ens.Base, data, 23) && ens.Firmware.Supports(FeatureSubsystemConfig) {
 		ptr = GenerateIndex(22, ens.Base.NameLen, ens.Base.Enstype)
 		ens.SubsystemConfig.Decode(data[ptr : ptr+BytesInInt32])
 	}
 }
+
+// Time will get the ensemble date and time with hundredth of a second
+// precision in the instrument time zone.  If the date or time is not
+// valid, like month 13 or Feb 30, an error is returned instead of
+// normalizing the date.
+func (ens *EnsembleDataSet) Time() (time.Time, error) {
+	loc := ens.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	// Validate the date
+	if ens.Month < 1 || ens.Month > 12 {
+		return time.Time{}, fmt.Errorf("invalid ensemble month %d", ens.Month)
+	}
+	if ens.Day < 1 || int(ens.Day) > daysInMonth(int(ens.Year), time.Month(ens.Month)) {
+		return time.Time{}, fmt.Errorf("invalid ensemble day %04d-%02d-%02d", ens.Year, ens.Month, ens.Day)
+	}
+
+	// Validate the time
+	if ens.Hour > 23 || ens.Minute > 59 || ens.Second > 59 || ens.HSec > 99 {
+		return time.Time{}, fmt.Errorf("invalid ensemble time %02d:%02d:%02d.%02d", ens.Hour, ens.Minute, ens.Second, ens.HSec)
+	}
+
+	return time.Date(int(ens.Year), time.Month(ens.Month), int(ens.Day),
+		int(ens.Hour), int(ens.Minute), int(ens.Second),
+		int(ens.HSec)*int(10*time.Millisecond), loc), nil
+}
+
+// daysInMonth will get the number of days in the month for the year.
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,100 @@
+package rti
+
+import (
+	"testing"
+	"time"
+)
+
+// TestEnsembleTime will check the ensemble time with hundredth of a second
+// precision and the invalid dates and times.
+func TestEnsembleTime(t *testing.T) {
+	tests := []struct {
+		year, month, day, hour, minute, second, hsec uint32
+		want                                         time.Time
+		ok                                           bool
+	}{
+		{2024, 2, 29, 12, 30, 45, 67, time.Date(2024, 2, 29, 12, 30, 45, 670000000, time.UTC), true},
+		{2023, 12, 31, 23, 59, 59, 99, time.Date(2023, 12, 31, 23, 59, 59, 990000000, time.UTC), true},
+		{2000, 2, 29, 0, 0, 0, 0, time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC), true},
+		{2023, 4, 30, 0, 0, 0, 0, time.Date(2023, 4, 30, 0, 0, 0, 0, time.UTC), true},
+		{2023, 2, 29, 0, 0, 0, 0, time.Time{}, false}, // Not a leap year
+		{1900, 2, 29, 0, 0, 0, 0, time.Time{}, false}, // Century is not a leap year
+		{2024, 2, 30, 0, 0, 0, 0, time.Time{}, false},
+		{2023, 4, 31, 0, 0, 0, 0, time.Time{}, false},
+		{2023, 13, 1, 0, 0, 0, 0, time.Time{}, false},
+		{2023, 0, 1, 0, 0, 0, 0, time.Time{}, false},
+		{2023, 1, 0, 0, 0, 0, 0, time.Time{}, false},
+		{2023, 1, 1, 24, 0, 0, 0, time.Time{}, false},
+		{2023, 1, 1, 0, 60, 0, 0, time.Time{}, false},
+		{2023, 1, 1, 0, 0, 60, 0, time.Time{}, false},
+		{2023, 1, 1, 0, 0, 0, 100, time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		ens := EnsembleDataSet{
+			Year: test.year, Month: test.month, Day: test.day,
+			Hour: test.hour, Minute: test.minute, Second: test.second, HSec: test.hsec,
+		}
+
+		got, err := ens.Time()
+		if !test.ok {
+			if err == nil {
+				t.Errorf("Time() of %04d-%02d-%02d %02d:%02d:%02d.%02d = %v, want an error", test.year, test.month, test.day, test.hour, test.minute, test.second, test.hsec, got)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Time() of %04d-%02d-%02d: %v", test.year, test.month, test.day, err)
+		} else if !got.Equal(test.want) {
+			t.Errorf("Time() = %v, want %v", got, test.want)
+		}
+	}
+}
+
+// TestEnsembleTimeLocation will check the ensemble time is in the instrument time zone.
+func TestEnsembleTimeLocation(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	ens := EnsembleDataSet{Year: 2024, Month: 1, Day: 1, Hour: 19, Location: loc}
+
+	got, err := ens.Time()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Time() = %v, want %v", got.UTC(), want)
+	}
+	if got.Location() != loc {
+		t.Errorf("Time() location = %v, want %v", got.Location(), loc)
+	}
+}
+
+// TestPingTime will check the first and last ping times are relative to the ensemble time.
+func TestPingTime(t *testing.T) {
+	var ens Ensemble
+	ens.EnsembleData = EnsembleDataSet{Year: 2024, Month: 6, Day: 1, Hour: 10, Second: 10, HSec: 50}
+	ens.AncillaryData.FirstPingTime = -1.25
+	ens.AncillaryData.LastPingTime = 0.5
+
+	first, err := ens.FirstPing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 6, 1, 10, 0, 9, 250000000, time.UTC); !first.Equal(want) {
+		t.Errorf("FirstPing() = %v, want %v", first, want)
+	}
+
+	last, err := ens.LastPing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 6, 1, 10, 0, 11, 0, time.UTC); !last.Equal(want) {
+		t.Errorf("LastPing() = %v, want %v", last, want)
+	}
+
+	ens.EnsembleData.Month = 13
+	if _, err := ens.FirstPing(); err == nil {
+		t.Error("FirstPing() of an invalid ensemble time did not return an error")
+	}
+}
@@ -1,5 +1,7 @@
 package rti
 
+import "fmt"
+
 // Firmware is the ADCP version.
 // It includes the subsystem configuraiton.
 type Firmware struct {
@@ -22,3 +24,73 @@ func (firm *Firmware) Decode(data []byte) {
 	firm.Revision = uint8(data[2])
 	firm.SubsystemCode = data[3]
 }
+
+// String will get the firmware version as Major.Minor.Revision.
+func (firm Firmware) String() string {
+	return fmt.Sprintf("%d.%d.%d", firm.Major, firm.Minior, firm.Revision)
+}
+
+// Compare will compare the firmware versions.  The subsystem code is not
+// compared.  The result is -1 if firm is older than other, 0 if they are the
+// same version and 1 if firm is newer than other.
+func (firm Firmware) Compare(other Firmware) int {
+	switch {
+	case firm.Major != other.Major:
+		return compareVersion(firm.Major, other.Major)
+	case firm.Minior != other.Minior:
+		return compareVersion(firm.Minior, other.Minior)
+	default:
+		return compareVersion(firm.Revision, other.Revision)
+	}
+}
+
+// AtLeast will check if the firmware is the same or newer than the version given.
+func (firm Firmware) AtLeast(major uint8, minor uint8, revision uint8) bool {
+	return firm.Compare(Firmware{Major: major, Minior: minor, Revision: revision}) >= 0
+}
+
+// Supports will check if the firmware supports the feature.
+// A feature is supported if the firmware is the same or newer than
+// the first firmware version with the feature.
+func (firm Firmware) Supports(feature Feature) bool {
+	first, ok := firmwareFeatures[feature]
+	if !ok {
+		return false
+	}
+
+	return firm.Compare(first) >= 0
+}
+
+// compareVersion will compare a single version number.
+func compareVersion(a uint8, b uint8) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Feature is a dataset or dataset layout that was added in a firmware release.
+type Feature int
+
+// Firmware Features
+const (
+	FeatureSubsystemConfig        Feature = iota // Subsystem configuration in the Ensemble Data Set
+	FeatureBottomTrackEngineering                // Bottom Track Engineering Data Set
+	FeatureSystemSetup                           // System Setup Data Set
+	FeatureRangeTracking                         // Range Tracking Data Set
+)
+
+// firmwareFeatures is the first firmware version with each feature.  The decoder
+// also checks the number of elements, so a short dataset is never read past its end.
+//
+// The first firmware with the Bottom Track Engineering, System Setup and Range
+// Tracking Data Sets is not confirmed by RTI yet, so they are not in the table and
+// Supports returns false for them.  The decoder skips those datasets until the
+// versions are added.
+var firmwareFeatures = map[Feature]Firmware{
+	FeatureSubsystemConfig: {Major: 0, Minior: 2, Revision: 13},
+}
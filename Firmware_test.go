@@ -0,0 +1,47 @@
+package rti
+
+import "testing"
+
+// TestFirmwareCompare will check the version comparison and string.
+func TestFirmwareCompare(t *testing.T) {
+	tests := []struct {
+		a, b Firmware
+		want int
+	}{
+		{Firmware{Major: 0, Minior: 2, Revision: 13}, Firmware{Major: 0, Minior: 2, Revision: 13}, 0},
+		{Firmware{Major: 0, Minior: 2, Revision: 12}, Firmware{Major: 0, Minior: 2, Revision: 13}, -1},
+		{Firmware{Major: 0, Minior: 3, Revision: 0}, Firmware{Major: 0, Minior: 2, Revision: 99}, 1},
+		{Firmware{Major: 1, Minior: 0, Revision: 0}, Firmware{Major: 0, Minior: 9, Revision: 9}, 1},
+		{Firmware{Major: 0, Minior: 2, Revision: 13, SubsystemCode: '3'}, Firmware{Major: 0, Minior: 2, Revision: 13, SubsystemCode: '1'}, 0},
+	}
+
+	for _, test := range tests {
+		if got := test.a.Compare(test.b); got != test.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+
+	if got := (Firmware{Major: 0, Minior: 2, Revision: 13}).String(); got != "0.2.13" {
+		t.Errorf("String() = %q, want %q", got, "0.2.13")
+	}
+}
+
+// TestFirmwareSupports will check the features are gated on the first firmware version.
+func TestFirmwareSupports(t *testing.T) {
+	tests := []struct {
+		firmware Firmware
+		feature  Feature
+		want     bool
+	}{
+		{Firmware{Major: 0, Minior: 2, Revision: 12}, FeatureSubsystemConfig, false},
+		{Firmware{Major: 0, Minior: 2, Revision: 13}, FeatureSubsystemConfig, true},
+		{Firmware{Major: 1, Minior: 0, Revision: 0}, FeatureSubsystemConfig, true},
+		{Firmware{Major: 9, Minior: 9, Revision: 9}, FeatureBottomTrackEngineering, false}, // Version not confirmed
+	}
+
+	for _, test := range tests {
+		if got := test.firmware.Supports(test.feature); got != test.want {
+			t.Errorf("%s.Supports(%d) = %v, want %v", test.firmware, test.feature, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package rti
+
+// GoodBeamDataSet will contain all the Good Beam Data set values.
+// These values describe the number of good pings for each beam.
+// The data will be stored in array.  The array size will be based off the
+// base data set.  The data is sent as integer values.
+// Bin x Beam
+type GoodBeamDataSet struct {
+	Base      BaseDataSet          // Base Dataset
+	GoodPings BinBeamMatrix[int32] // Number of good pings
+}
+
+// Decode will take the binary data and decode into
+// into the ensemble data set.
+func (good *GoodBeamDataSet) Decode(data []byte) {
+
+	// Decode the [Bins][Beams] data
+	good.GoodPings = decodeBinBeamMatrix[int32](&good.Base, data)
+
+}
@@ -0,0 +1,20 @@
+package rti
+
+// GoodEarthDataSet will contain all the Good Earth Data set values.
+// These values describe the number of good pings for each earth velocity component.
+// The data will be stored in array.  The array size will be based off the
+// base data set.  The data is sent as integer values.
+// Bin x Beam
+type GoodEarthDataSet struct {
+	Base      BaseDataSet          // Base Dataset
+	GoodPings BinBeamMatrix[int32] // Number of good pings
+}
+
+// Decode will take the binary data and decode into
+// into the ensemble data set.
+func (good *GoodEarthDataSet) Decode(data []byte) {
+
+	// Decode the [Bins][Beams] data
+	good.GoodPings = decodeBinBeamMatrix[int32](&good.Base, data)
+
+}
@@ -0,0 +1,305 @@
+package rti
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WGS84 ellipsoid and geomagnetic reference radius
+const wgs84A = 6378.137                 // Semi-major axis in km
+const wgs84F = 1.0 / 298.257223563      // Flattening
+const wgs84E2 = wgs84F * (2.0 - wgs84F) // Eccentricity squared
+const geomagRefRadius = 6371.2          // Geomagnetic reference radius in km
+
+// Position is a location on the earth.
+type Position struct {
+	Latitude  float64 // Latitude in degrees, North is positive
+	Longitude float64 // Longitude in degrees, East is positive
+	Height    float64 // Height above the WGS84 ellipsoid in meters
+}
+
+// MagneticModel is a spherical harmonic model of the earth's main magnetic field
+// like the World Magnetic Model.  The model is evaluated offline from its
+// coefficient table.
+type MagneticModel struct {
+	Name   string  // Model name
+	Epoch  float64 // Model epoch in decimal years
+	Degree int     // Maximum degree of the model
+
+	g    [][]float64 // Gauss coefficients g in nT, Schmidt normalized
+	h    [][]float64 // Gauss coefficients h in nT, Schmidt normalized
+	gDot [][]float64 // Secular variation of g in nT/year
+	hDot [][]float64 // Secular variation of h in nT/year
+}
+
+// WMM is the World Magnetic Model embedded in the package.  It is WMM2020 and
+// is only valid from 2020.0 to 2025.0.  For newer data, load the current WMM.COF
+// with LoadMagneticModel.  Ensembles outside the life of the model are decoded
+// with the fixed declination and DeclinationFromModel is false.
+var WMM = mustLoadMagneticModel(wmm2020)
+
+// mustLoadMagneticModel will load the embedded coefficient table.
+func mustLoadMagneticModel(cof string) *MagneticModel {
+	model, err := LoadMagneticModel(strings.NewReader(cof))
+	if err != nil {
+		panic(err)
+	}
+
+	return model
+}
+
+// LoadMagneticModel will load a magnetic model from a WMM.COF coefficient file.
+// The first line is the epoch and model name.  Each following line is
+// n m g h gdot hdot.  The file can end with a line of 9s.
+func LoadMagneticModel(r io.Reader) (*MagneticModel, error) {
+	scanner := bufio.NewScanner(r)
+
+	// Header
+	if !scanner.Scan() {
+		return nil, errors.New("magnetic model is empty")
+	}
+	header := strings.Fields(scanner.Text())
+	if len(header) < 2 {
+		return nil, fmt.Errorf("invalid magnetic model header %q", scanner.Text())
+	}
+	epoch, err := strconv.ParseFloat(header[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid magnetic model epoch %q", header[0])
+	}
+
+	model := &MagneticModel{Name: header[1], Epoch: epoch}
+
+	type coefficient struct {
+		n, m             int
+		g, h, gDot, hDot float64
+	}
+	var coefs []coefficient
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		// End of the coefficients
+		if strings.HasPrefix(line, "9999") {
+			break
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("invalid magnetic model line %q", line)
+		}
+
+		var c coefficient
+		if c.n, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid magnetic model line %q", line)
+		}
+		if c.m, err = strconv.Atoi(fields[1]); err != nil || c.m > c.n || c.n < 1 {
+			return nil, fmt.Errorf("invalid magnetic model line %q", line)
+		}
+		values := make([]float64, 4)
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(fields[i+2], 64); err != nil {
+				return nil, fmt.Errorf("invalid magnetic model line %q", line)
+			}
+		}
+		c.g, c.h, c.gDot, c.hDot = values[0], values[1], values[2], values[3]
+
+		if c.n > model.Degree {
+			model.Degree = c.n
+		}
+		coefs = append(coefs, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if model.Degree == 0 {
+		return nil, errors.New("magnetic model has no coefficients")
+	}
+
+	model.g = newTriangle(model.Degree)
+	model.h = newTriangle(model.Degree)
+	model.gDot = newTriangle(model.Degree)
+	model.hDot = newTriangle(model.Degree)
+	for _, c := range coefs {
+		model.g[c.n][c.m] = c.g
+		model.h[c.n][c.m] = c.h
+		model.gDot[c.n][c.m] = c.gDot
+		model.hDot[c.n][c.m] = c.hDot
+	}
+
+	return model, nil
+}
+
+// ModelDeclination will compute the magnetic declination in degrees from the model.
+// The GPS position in the NMEA data is used.  If there is no GPS position,
+// the deployment position is used.  The ensemble time is used for the date.
+// If the ensemble time is outside the 5 year life of the model, an error is
+// returned, because the extrapolated declination is not accurate.  Load a newer
+// model with LoadMagneticModel for these ensembles.
+func (ens *Ensemble) ModelDeclination(model *MagneticModel, deployment *Position) (float64, error) {
+	t, err := ens.GetEnsembleData().Time()
+	if err != nil {
+		return 0, err
+	}
+	if !model.Valid(t) {
+		return 0, fmt.Errorf("ensemble time %s is outside the life of the %s magnetic model", t.Format(time.RFC3339), model.Name)
+	}
+
+	nmea := ens.GetNmeaData()
+	switch {
+	case nmea.HasPosition:
+		return model.Declination(nmea.Position, t), nil
+	case deployment != nil:
+		return model.Declination(*deployment, t), nil
+	default:
+		return 0, errors.New("no position to compute the magnetic declination")
+	}
+}
+
+// Valid will check if the time is within the 5 year life of the model.
+// Outside of that time the model is extrapolated and less accurate.
+func (model *MagneticModel) Valid(t time.Time) bool {
+	year := decimalYear(t)
+	return year >= model.Epoch && year < model.Epoch+5.0
+}
+
+// Declination will get the magnetic declination in degrees at the position and time.
+// East declination is positive.
+func (model *MagneticModel) Declination(pos Position, t time.Time) float64 {
+	north, east, _ := model.Field(pos, t)
+	return math.Atan2(east, north) * (180.0 / math.Pi)
+}
+
+// Field will get the north, east and down components of the magnetic field
+// in nT at the position and time.
+func (model *MagneticModel) Field(pos Position, t time.Time) (north float64, east float64, down float64) {
+	deg := math.Pi / 180.0
+	dt := decimalYear(t) - model.Epoch
+
+	// Geodetic to geocentric spherical coordinates
+	lat := pos.Latitude * deg
+	lon := pos.Longitude * deg
+	height := pos.Height / 1000.0
+	sinLat, cosLat := math.Sincos(lat)
+	rc := wgs84A / math.Sqrt(1.0-wgs84E2*sinLat*sinLat)
+	p := (rc + height) * cosLat
+	z := (rc*(1.0-wgs84E2) + height) * sinLat
+	r := math.Hypot(p, z)
+	latC := math.Asin(z / r)
+
+	// Colatitude
+	ct := math.Sin(latC)
+	st := math.Cos(latC)
+	if st < 1e-10 {
+		st = 1e-10
+	}
+
+	// Schmidt semi-normalized associated Legendre functions
+	// and their derivatives with respect to the colatitude
+	pnm, dpnm := schmidtLegendre(model.Degree, ct, st)
+
+	var br, bt, bp float64
+	for n := 1; n <= model.Degree; n++ {
+		ar := math.Pow(geomagRefRadius/r, float64(n+2))
+		for m := 0; m <= n; m++ {
+			g := model.g[n][m] + dt*model.gDot[n][m]
+			h := model.h[n][m] + dt*model.hDot[n][m]
+			sinM, cosM := math.Sincos(float64(m) * lon)
+
+			br += ar * float64(n+1) * (g*cosM + h*sinM) * pnm[n][m]
+			bt -= ar * (g*cosM + h*sinM) * dpnm[n][m]
+			bp += ar * float64(m) * (g*sinM - h*cosM) * pnm[n][m] / st
+		}
+	}
+
+	// Geocentric north, east and down
+	xc := -bt
+	yc := bp
+	zc := -br
+
+	// Rotate to geodetic
+	psi := latC - lat
+	sinPsi, cosPsi := math.Sincos(psi)
+	north = xc*cosPsi - zc*sinPsi
+	east = yc
+	down = xc*sinPsi + zc*cosPsi
+
+	return north, east, down
+}
+
+// schmidtLegendre will compute the Schmidt semi-normalized associated Legendre
+// functions of cos(colatitude) and their derivatives with respect to the colatitude.
+func schmidtLegendre(degree int, ct float64, st float64) ([][]float64, [][]float64) {
+	pnm := newTriangle(degree)
+	dpnm := newTriangle(degree)
+
+	// Gauss normalized
+	pnm[0][0] = 1.0
+	for n := 1; n <= degree; n++ {
+		for m := 0; m <= n; m++ {
+			if n == m {
+				pnm[n][m] = st * pnm[n-1][m-1]
+				dpnm[n][m] = st*dpnm[n-1][m-1] + ct*pnm[n-1][m-1]
+				continue
+			}
+
+			var k float64
+			var p2, dp2 float64
+			if n > 1 {
+				k = float64((n-1)*(n-1)-m*m) / float64((2*n-1)*(2*n-3))
+				if m <= n-2 {
+					p2, dp2 = pnm[n-2][m], dpnm[n-2][m]
+				}
+			}
+			pnm[n][m] = ct*pnm[n-1][m] - k*p2
+			dpnm[n][m] = ct*dpnm[n-1][m] - st*pnm[n-1][m] - k*dp2
+		}
+	}
+
+	// Gauss to Schmidt semi-normalized
+	schmidt := 1.0
+	for n := 1; n <= degree; n++ {
+		schmidt *= float64(2*n-1) / float64(n)
+		s := schmidt
+		for m := 0; m <= n; m++ {
+			if m > 0 {
+				j := 1.0
+				if m == 1 {
+					j = 2.0
+				}
+				s *= math.Sqrt(float64(n-m+1) * j / float64(n+m))
+			}
+			pnm[n][m] *= s
+			dpnm[n][m] *= s
+		}
+	}
+
+	return pnm, dpnm
+}
+
+// newTriangle will create a triangle array for the degree and order.
+func newTriangle(degree int) [][]float64 {
+	tri := make([][]float64, degree+1)
+	for n := range tri {
+		tri[n] = make([]float64, n+1)
+	}
+
+	return tri
+}
+
+// decimalYear will get the time as a decimal year.
+func decimalYear(t time.Time) float64 {
+	t = t.UTC()
+	start := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+
+	return float64(t.Year()) + t.Sub(start).Seconds()/end.Sub(start).Seconds()
+}
@@ -0,0 +1,95 @@
+package rti
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestWMMTestValues will check the model against the WMM2020 test values
+// published with the model.  The height is above the WGS84 ellipsoid in km.
+func TestWMMTestValues(t *testing.T) {
+	tests := []struct {
+		year                   float64
+		height, lat, lon       float64
+		north, east, down, dec float64
+	}{
+		{2020.0, 0, 80, 0, 6570.4, -146.3, 54606.0, -1.28},
+		{2020.0, 0, 0, 120, 39624.3, 109.9, -10932.5, 0.16},
+		{2020.0, 0, -80, 240, 5940.6, 15772.1, -52480.8, 69.36},
+		{2020.0, 100, 80, 0, 6261.8, -185.5, 52429.1, -1.70},
+		{2020.0, 100, 0, 120, 37636.7, 104.9, -10474.8, 0.16},
+		{2020.0, 100, -80, 240, 5744.9, 14799.5, -49969.4, 68.78},
+		{2022.5, 0, 80, 0, 6529.9, 1.1, 54713.4, 0.01},
+		{2022.5, 0, 0, 120, 39684.7, -42.2, -10809.5, -0.06},
+		{2022.5, 0, -80, 240, 6016.5, 15776.7, -52251.6, 69.13},
+		{2022.5, 100, 80, 0, 6224.0, -44.5, 52527.0, -0.41},
+		{2022.5, 100, 0, 120, 37694.0, -35.3, -10362.0, -0.05},
+		{2022.5, 100, -80, 240, 5815.0, 14803.0, -49755.3, 68.55},
+	}
+
+	for _, test := range tests {
+		tm := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		if test.year == 2022.5 {
+			tm = time.Date(2022, 7, 2, 12, 0, 0, 0, time.UTC)
+		}
+		pos := Position{Latitude: test.lat, Longitude: test.lon, Height: test.height * 1000}
+
+		north, east, down := WMM.Field(pos, tm)
+		if math.Abs(north-test.north) > 0.1 || math.Abs(east-test.east) > 0.1 || math.Abs(down-test.down) > 0.1 {
+			t.Errorf("Field(%g, %g, %g km, %g) = %.1f %.1f %.1f, want %.1f %.1f %.1f", test.lat, test.lon, test.height, test.year, north, east, down, test.north, test.east, test.down)
+		}
+
+		if dec := WMM.Declination(pos, tm); math.Abs(dec-test.dec) > 0.01 {
+			t.Errorf("Declination(%g, %g, %g km, %g) = %.2f, want %.2f", test.lat, test.lon, test.height, test.year, dec, test.dec)
+		}
+	}
+}
+
+// TestMagneticModelValid will check the 5 year life of the model.
+func TestMagneticModelValid(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2019, 12, 31, 23, 59, 59, 0, time.UTC), false},
+		{time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC), true},
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, test := range tests {
+		if got := WMM.Valid(test.t); got != test.want {
+			t.Errorf("Valid(%v) = %v, want %v", test.t, got, test.want)
+		}
+	}
+}
+
+// TestApplyDeclination will check the model declination is used within the life
+// of the model and the fixed declination is used and flagged outside of it.
+func TestApplyDeclination(t *testing.T) {
+	opts := DecodeOptions{Declination: 5, MagneticModel: WMM}
+	pos := Position{Latitude: 0, Longitude: 120}
+
+	tests := []struct {
+		year      uint32
+		want      float32
+		fromModel bool
+	}{
+		{2020, float32(WMM.Declination(pos, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))), true},
+		{2026, 5, false},
+	}
+
+	for _, test := range tests {
+		var ens Ensemble
+		ens.EnsembleData = EnsembleDataSet{Year: test.year, Month: 1, Day: 1}
+		ens.NmeaData.HasPosition = true
+		ens.NmeaData.Position = pos
+
+		opts.applyDeclination(&ens)
+		earth := ens.GetEarthVelocityData()
+		if math.Abs(float64(earth.Declination-test.want)) > 1e-4 || earth.DeclinationFromModel != test.fromModel {
+			t.Errorf("%d: declination %g from model %v, want %g from model %v", test.year, earth.Declination, earth.DeclinationFromModel, test.want, test.fromModel)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package rti
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MovingBedTestType is the type of moving bed test.
+type MovingBedTestType int
+
+// Moving bed test types
+const (
+	MovingBedLoop       MovingBedTestType = iota // Boat crosses the river and returns to the start
+	MovingBedStationary                          // Boat holds a position in the river
+)
+
+// String will get the name of the test type.
+func (test MovingBedTestType) String() string {
+	if test == MovingBedStationary {
+		return "Stationary"
+	}
+
+	return "Loop"
+}
+
+// Moving bed threshold as a percent of the water speed
+const movingBedThreshold = 1.0
+
+// Most ensembles with invalid Bottom Track as a percent for a valid test
+const maxInvalidBottomTrack = 10.0
+
+// MovingBedTest is a loop or stationary moving bed test.  A moving bed biases
+// the Bottom Track boat velocity upstream, so the Bottom Track referenced discharge
+// is too low.
+//
+// The loop test compares the Bottom Track boat track to the GPS track.  The boat
+// returns to the start, so the difference at the end is the distance the bed moved.
+// The stationary test assumes the boat did not move, so the Bottom Track drift is
+// the distance the bed moved.
+type MovingBedTest struct {
+	Type      MovingBedTestType // Type of test
+	Ensembles []Ensemble        // Ensembles of the test in time order
+
+	MinGPSQuality int            // Minimum GGA fix quality for the loop test.  If 0, any fix is used
+	MaxHDOP       float64        // Maximum GGA HDOP for the loop test.  If 0, HDOP is not checked
+	SideLobe      SideLobeFilter // Side lobe cutoff settings for the water speed
+}
+
+// MovingBedResult is the result of a moving bed test.
+type MovingBedResult struct {
+	Type         MovingBedTestType // Type of test
+	Duration     float64           // Duration of the test in seconds
+	NumEnsembles int               // Number of ensembles in the test with good Bottom Track
+	InvalidBT    int               // Number of ensembles in the test with interpolated Bottom Track
+
+	BottomTrackEast  float64 // East distance made good of the Bottom Track boat track in meters
+	BottomTrackNorth float64 // North distance made good of the Bottom Track boat track in meters
+	GPSEast          float64 // East distance made good of the GPS track in meters.  Loop test only
+	GPSNorth         float64 // North distance made good of the GPS track in meters.  Loop test only
+
+	Velocity       float64 // Moving bed velocity in m/s
+	Direction      float64 // Direction the bed moves in degrees true
+	WaterSpeed     float64 // Mean water speed corrected for the moving bed in m/s
+	WaterDirection float64 // Mean water direction in degrees true
+	Percent        float64 // Moving bed velocity as a percent of the water speed
+	MovingBed      bool    // Flag if the moving bed is more than 1% of the water speed
+	Correction     float64 // Percent correction to apply to the Bottom Track referenced discharge
+}
+
+// Analyze will compute the moving bed velocity of the test.  The Bottom Track boat
+// track is the integral of the opposite of the Bottom Track Earth velocity over the
+// time of each ensemble.  The Bottom Track velocity of the ensembles with invalid
+// Bottom Track is interpolated from the good ensembles around them.  The loop test
+// only uses the ensembles from the first to the last good GPS position, so both
+// tracks cover the same time.  If more than 10% of the ensembles have invalid Bottom
+// Track, the result is returned with an error.
+// Moving Bed Velocity = (GPS Track - Bottom Track Track) / Duration
+func (test *MovingBedTest) Analyze() (MovingBedResult, error) {
+	result := MovingBedResult{Type: test.Type}
+	proc := ReferenceProcessor{
+		Priority:      []VelocityReference{ReferenceBottomTrack},
+		MinGPSQuality: test.MinGPSQuality,
+		MaxHDOP:       test.MaxHDOP,
+	}
+
+	// Bottom Track reference and GPS position of each ensemble
+	type record struct {
+		t           time.Time
+		ens         *Ensemble
+		good        bool      // Good Bottom Track
+		east, north float64   // Bottom Track reference in m/s
+		fix         *Position // Good GPS position
+	}
+	var recs []record
+	for i := range test.Ensembles {
+		ens := &test.Ensembles[i]
+		t, err := ens.GetEnsembleData().Time()
+		if err != nil {
+			continue
+		}
+
+		rec := record{t: t, ens: ens}
+		if nmea := ens.GetNmeaData(); nmea.HasPosition && proc.goodFix(nmea) {
+			pos := nmea.Position
+			rec.fix = &pos
+		}
+
+		// The boat velocity is the opposite of the Bottom Track velocity
+		ref, east, north, _ := proc.reference(ens)
+		rec.good = ref != ReferenceNone
+		rec.east, rec.north = east, north
+
+		recs = append(recs, rec)
+	}
+
+	// The loop test is from the first to the last good GPS position
+	start, end := 0, len(recs)-1
+	if test.Type == MovingBedLoop {
+		for start <= end && recs[start].fix == nil {
+			start++
+		}
+		for end >= start && recs[end].fix == nil {
+			end--
+		}
+		if start >= end {
+			return result, errors.New("loop test needs a GPS position at the start and end")
+		}
+	}
+	if start > end {
+		return result, errors.New("no ensembles with a valid time")
+	}
+	recs = recs[start : end+1]
+
+	// Interpolate the invalid Bottom Track
+	var good []int
+	for i := range recs {
+		if recs[i].good {
+			good = append(good, i)
+		}
+	}
+	result.NumEnsembles = len(good)
+	result.InvalidBT = len(recs) - len(good)
+	if len(good) == 0 {
+		return result, errors.New("no ensembles with good bottom track")
+	}
+
+	next := 0
+	for i := range recs {
+		if recs[i].good {
+			continue
+		}
+		for next < len(good) && good[next] < i {
+			next++
+		}
+
+		switch {
+		case next == 0:
+			recs[i].east, recs[i].north = recs[good[0]].east, recs[good[0]].north
+		case next == len(good):
+			last := recs[good[len(good)-1]]
+			recs[i].east, recs[i].north = last.east, last.north
+		default:
+			a, b := recs[good[next-1]], recs[good[next]]
+			frac := float64(recs[i].t.Sub(a.t)) / float64(b.t.Sub(a.t))
+			recs[i].east = a.east + frac*(b.east-a.east)
+			recs[i].north = a.north + frac*(b.north-a.north)
+		}
+	}
+
+	// Bottom Track boat track
+	var waterEast, waterNorth float64
+	numWater := 0
+	for i, rec := range recs {
+		if i > 0 {
+			dt := rec.t.Sub(recs[i-1].t).Seconds()
+			result.BottomTrackEast -= rec.east * dt
+			result.BottomTrackNorth -= rec.north * dt
+		}
+
+		// Bottom Track referenced water velocity
+		if rec.good {
+			if east, north, ok := test.meanWaterVelocity(rec.ens, rec.east, rec.north); ok {
+				waterEast += east
+				waterNorth += north
+				numWater++
+			}
+		}
+	}
+
+	result.Duration = recs[len(recs)-1].t.Sub(recs[0].t).Seconds()
+	if result.Duration <= 0 {
+		return result, errors.New("moving bed test has no duration")
+	}
+
+	// Distance the bed moved
+	var bedEast, bedNorth float64
+	if test.Type == MovingBedLoop {
+		result.GPSEast, result.GPSNorth = flatEarthDistance(*recs[0].fix, *recs[len(recs)-1].fix)
+		bedEast = result.GPSEast - result.BottomTrackEast
+		bedNorth = result.GPSNorth - result.BottomTrackNorth
+	} else {
+		bedEast = -result.BottomTrackEast
+		bedNorth = -result.BottomTrackNorth
+	}
+
+	bedEast /= result.Duration
+	bedNorth /= result.Duration
+	result.Velocity = math.Hypot(bedEast, bedNorth)
+	result.Direction = compassDirection(bedEast, bedNorth)
+
+	if numWater == 0 {
+		return result, errors.New("no good water velocities for the water speed")
+	}
+
+	// The Bottom Track referenced water velocity is low by the moving bed velocity
+	waterEast = waterEast/float64(numWater) + bedEast
+	waterNorth = waterNorth/float64(numWater) + bedNorth
+	result.WaterSpeed = math.Hypot(waterEast, waterNorth)
+	result.WaterDirection = compassDirection(waterEast, waterNorth)
+	if result.WaterSpeed == 0 {
+		return result, errors.New("water speed is zero")
+	}
+
+	result.Percent = result.Velocity / result.WaterSpeed * 100.0
+	result.MovingBed = result.Percent > movingBedThreshold
+
+	// Only the moving bed in the direction of the flow biases the discharge
+	// The Bottom Track referenced water speed is used for the correction
+	downstream := (bedEast*waterEast + bedNorth*waterNorth) / result.WaterSpeed
+	if measured := result.WaterSpeed - downstream; measured > 0 && result.MovingBed {
+		result.Correction = downstream / measured * 100.0
+	}
+
+	// Too much invalid Bottom Track for a good test
+	if invalid := float64(result.InvalidBT) / float64(len(recs)) * 100.0; invalid > maxInvalidBottomTrack {
+		return result, fmt.Errorf("%.0f%% of the ensembles have invalid bottom track", invalid)
+	}
+
+	return result, nil
+}
+
+// meanWaterVelocity will get the depth averaged East and North water velocity of the
+// good bins above the side lobe cutoff.  The reference is removed if the Earth
+// velocities are relative to the ADCP.
+func (test *MovingBedTest) meanWaterVelocity(ens *Ensemble, refEast float64, refNorth float64) (float64, float64, bool) {
+	earth := ens.GetEarthVelocityData()
+	if earth.Velocity.NumBeams <= EarthNorth {
+		return 0, 0, false
+	}
+	if earth.Reference != ReferenceNone {
+		refEast, refNorth = 0, 0
+	}
+
+	ranges := ens.BinRanges()
+	cutoff, ok := test.SideLobe.cutoff(ens)
+	if !ok {
+		cutoff = math.Inf(1)
+	}
+
+	var east, north float64
+	count := 0
+	for bin := 0; bin < earth.Velocity.NumBins && bin < len(ranges); bin++ {
+		if float64(ranges[bin]) > cutoff {
+			break
+		}
+
+		e := earth.Velocity.At(bin, EarthEast)
+		n := earth.Velocity.At(bin, EarthNorth)
+		if IsBad(e) || IsBad(n) {
+			continue
+		}
+
+		east += float64(e) - refEast
+		north += float64(n) - refNorth
+		count++
+	}
+
+	if count == 0 {
+		return 0, 0, false
+	}
+
+	return east / float64(count), north / float64(count), true
+}
+
+// compassDirection will get the direction of the East and North vector in degrees
+// from North between 0 and 360.
+func compassDirection(east float64, north float64) float64 {
+	dir := math.Atan2(east, north) * (180.0 / math.Pi)
+	if dir < 0 {
+		dir += 360.0
+	}
+
+	return dir
+}
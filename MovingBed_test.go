@@ -0,0 +1,144 @@
+package rti
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// metersToLatitude will convert a North distance in meters to degrees of latitude
+// with the same flat earth as flatEarthDistance.
+func metersToLatitude(north float64) float64 {
+	return north / (wgs84A * 1000.0) * (180.0 / math.Pi)
+}
+
+// newMovingBedTest will create a moving bed test of 11 ensembles 10 seconds apart.
+// The water moves 1 m/s East, the bed moves 0.1 m/s East and the boat drifts
+// 0.1 m/s North, so the GPS track is 10 m North.  The Earth and Bottom Track
+// velocities are relative to the ADCP like an RTI ADCP measures them.
+// Bottom Track = Bed - Boat = (0.1, -0.1)
+// Earth = Water - Boat = (1, -0.1)
+func newMovingBedTest(testType MovingBedTestType) MovingBedTest {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	test := MovingBedTest{Type: testType}
+	for i := 0; i <= 10; i++ {
+		var ens Ensemble
+		setEnsembleTime(&ens.EnsembleData, start.Add(time.Duration(i)*10*time.Second))
+		ens.EnsembleData.NumBins = 1
+		ens.AncillaryData.BinSize = 1
+		ens.AncillaryData.FirstBinRange = 1
+
+		ens.EarthVelocityData.Base.ElementMultiplier = numVelocityComponents
+		ens.EarthVelocityData.Velocity = NewBinBeamMatrix[float32](1, numVelocityComponents)
+		ens.EarthVelocityData.Velocity.Set(0, EarthEast, 1)
+		ens.EarthVelocityData.Velocity.Set(0, EarthNorth, -0.1)
+
+		ens.BottomTrackData.EarthVelocity = []float32{0.1, -0.1, 0, 0}
+
+		ens.NmeaData.HasPosition = true
+		ens.NmeaData.Position = Position{Latitude: metersToLatitude(float64(i))}
+
+		test.Ensembles = append(test.Ensembles, ens)
+	}
+
+	return test
+}
+
+// TestMovingBedTest will check the loop and stationary tests with the same data.
+// The loop test removes the boat drift with the GPS track, so the bed moves 0.1 m/s
+// East.  The stationary test assumes the boat did not move, so the drift is added
+// to the bed velocity.
+// Loop:       Bed = (0.1, 0), Water = (1, 0), Correction = 0.1 / 0.9
+// Stationary: Bed = (0.1, -0.1), Water = (1, -0.1), Correction = 0.11 / 0.9
+func TestMovingBedTest(t *testing.T) {
+	tests := []struct {
+		testType   MovingBedTestType
+		velocity   float64
+		direction  float64
+		percent    float64
+		correction float64
+	}{
+		{MovingBedLoop, 0.1, 90, 10, 11.111111},
+		{MovingBedStationary, 0.141421, 135, 14.071951, 12.222222},
+	}
+
+	for _, test := range tests {
+		mb := newMovingBedTest(test.testType)
+		result, err := mb.Analyze()
+		if err != nil {
+			t.Fatalf("%s: %v", test.testType, err)
+		}
+
+		if math.Abs(result.Velocity-test.velocity) > 1e-5 || math.Abs(result.Direction-test.direction) > 1e-3 {
+			t.Errorf("%s: velocity %g direction %g, want %g %g", test.testType, result.Velocity, result.Direction, test.velocity, test.direction)
+		}
+		if math.Abs(result.Percent-test.percent) > 1e-4 || math.Abs(result.Correction-test.correction) > 1e-4 {
+			t.Errorf("%s: percent %g correction %g, want %g %g", test.testType, result.Percent, result.Correction, test.percent, test.correction)
+		}
+		if !result.MovingBed || result.Duration != 100 || result.NumEnsembles != 11 {
+			t.Errorf("%s: moving bed %t duration %g ensembles %d, want true 100 11", test.testType, result.MovingBed, result.Duration, result.NumEnsembles)
+		}
+	}
+}
+
+// TestMovingBedInvalidBottomTrack will check the invalid Bottom Track is interpolated
+// and the test is invalid with more than 10% of the ensembles invalid.
+func TestMovingBedInvalidBottomTrack(t *testing.T) {
+	tests := []struct {
+		invalid []int
+		isErr   bool
+	}{
+		{[]int{5}, false},      // 1 of 11 is 9%
+		{[]int{0, 10}, true},   // 2 of 11 is 18%, first and last use the nearest good value
+		{[]int{4, 5, 6}, true}, // 3 of 11 is 27%
+	}
+
+	for _, test := range tests {
+		mb := newMovingBedTest(MovingBedStationary)
+		for _, i := range test.invalid {
+			mb.Ensembles[i].BottomTrackData.EarthVelocity = []float32{BadVelocity, BadVelocity, BadVelocity, BadVelocity}
+		}
+
+		result, err := mb.Analyze()
+		if (err != nil) != test.isErr {
+			t.Errorf("invalid %v: error %v, want error %t", test.invalid, err, test.isErr)
+		}
+
+		// The interpolated Bottom Track is the same as the good Bottom Track
+		if result.InvalidBT != len(test.invalid) || math.Abs(result.Velocity-0.141421) > 1e-5 {
+			t.Errorf("invalid %v: invalid bt %d velocity %g, want %d 0.141421", test.invalid, result.InvalidBT, result.Velocity, len(test.invalid))
+		}
+	}
+}
+
+// TestMovingBedLoopGPS will check the loop test only uses the ensembles from the
+// first to the last good GPS position.
+func TestMovingBedLoopGPS(t *testing.T) {
+	mb := newMovingBedTest(MovingBedLoop)
+
+	// No GPS at the first and last ensemble and the Bottom Track is far off
+	for _, i := range []int{0, 10} {
+		mb.Ensembles[i].NmeaData.HasPosition = false
+		mb.Ensembles[i].BottomTrackData.EarthVelocity = []float32{5, 5, 0, 0}
+	}
+
+	result, err := mb.Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Duration != 80 || result.NumEnsembles != 9 || math.Abs(result.GPSNorth-8) > 1e-6 {
+		t.Errorf("duration %g ensembles %d gps north %g, want 80 9 8", result.Duration, result.NumEnsembles, result.GPSNorth)
+	}
+	if math.Abs(result.Velocity-0.1) > 1e-5 || math.Abs(result.Direction-90) > 1e-3 {
+		t.Errorf("velocity %g direction %g, want 0.1 90", result.Velocity, result.Direction)
+	}
+
+	// A loop test needs GPS
+	for i := range mb.Ensembles {
+		mb.Ensembles[i].NmeaData.HasPosition = false
+	}
+	if _, err := mb.Analyze(); err == nil {
+		t.Error("loop test with no GPS did not return an error")
+	}
+}
@@ -0,0 +1,174 @@
+package rti
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NmeaDataSet will contain all the NMEA Data set values.
+// The NMEA data is the GPS data received by the ADCP during the ensemble.
+// The data is sent as bytes of ASCII NMEA sentences.
+type NmeaDataSet struct {
+	Base          BaseDataSet // Base Dataset
+	Sentences     []string    // NMEA sentences with a valid checksum
+	HasPosition   bool        // Flag if a GGA sentence with a fix was found
+	Position      Position    // GPS position from the GGA sentence
+	FixQuality    int         // GGA fix quality, 0 is no fix
+	NumSatellites int         // GGA number of satellites
+	HDOP          float64     // GGA horizontal dilution of precision
+	HasVelocity   bool        // Flag if a VTG sentence was found
+	Course        float64     // VTG course over ground in degrees true
+	Speed         float64     // VTG speed over ground in m/s
+}
+
+// Decode will take the binary data and decode into
+// into the ensemble data set.
+func (nmea *NmeaDataSet) Decode(data []byte) {
+	// Clear any previous values
+	*nmea = NmeaDataSet{Base: nmea.Base}
+
+	// Not enough data
+	ptr := getHeaderSize(nmea.Base.NameLen)
+	if len(data) <= ptr {
+		return
+	}
+
+	// NMEA sentences are separated by new lines
+	// and the data can be padded with nulls
+	text := strings.Trim(string(data[ptr:]), "\x00")
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		line = strings.Trim(line, "\x00 ")
+		if !validNmeaChecksum(line) {
+			continue
+		}
+		nmea.Sentences = append(nmea.Sentences, line)
+
+		// Remove the checksum and split the fields
+		if i := strings.IndexByte(line, '*'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Split(line, ",")
+		if len(fields[0]) < 6 {
+			continue
+		}
+
+		// Sentence type without the talker ID
+		switch fields[0][3:] {
+		case "GGA":
+			nmea.decodeGGA(fields)
+		case "VTG":
+			nmea.decodeVTG(fields)
+		}
+	}
+}
+
+// decodeGGA will decode the position from the GGA sentence.
+// $GPGGA,time,lat,N,lon,E,quality,satellites,hdop,altitude,M,geoid,M,,
+func (nmea *NmeaDataSet) decodeGGA(fields []string) {
+	if len(fields) < 12 {
+		return
+	}
+
+	quality, err := strconv.Atoi(fields[6])
+	if err != nil || quality == 0 {
+		return
+	}
+
+	lat, okLat := parseNmeaCoordinate(fields[2], fields[3], 2)
+	lon, okLon := parseNmeaCoordinate(fields[4], fields[5], 3)
+	if !okLat || !okLon {
+		return
+	}
+
+	nmea.HasPosition = true
+	nmea.FixQuality = quality
+	nmea.Position.Latitude = lat
+	nmea.Position.Longitude = lon
+	nmea.NumSatellites, _ = strconv.Atoi(fields[7])
+	nmea.HDOP, _ = strconv.ParseFloat(fields[8], 64)
+
+	// Height above the ellipsoid is the altitude plus the geoid separation
+	alt, _ := strconv.ParseFloat(fields[9], 64)
+	geoid, _ := strconv.ParseFloat(fields[11], 64)
+	nmea.Position.Height = alt + geoid
+}
+
+// decodeVTG will decode the course and speed over ground from the VTG sentence.
+// $GPVTG,course,T,course,M,knots,N,kmh,K,mode
+func (nmea *NmeaDataSet) decodeVTG(fields []string) {
+	if len(fields) < 9 {
+		return
+	}
+
+	course, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return
+	}
+
+	// Use km/h if given, otherwise knots
+	if kmh, err := strconv.ParseFloat(fields[7], 64); err == nil {
+		nmea.Speed = kmh / 3.6
+	} else if knots, err := strconv.ParseFloat(fields[5], 64); err == nil {
+		nmea.Speed = knots * (1852.0 / 3600.0)
+	} else {
+		return
+	}
+
+	nmea.HasVelocity = true
+	nmea.Course = course
+}
+
+// parseNmeaCoordinate will parse the NMEA coordinate ddmm.mmmm into degrees.
+// The number of degree digits is 2 for latitude and 3 for longitude.
+// South and West are negative.
+func parseNmeaCoordinate(value string, hemisphere string, degreeDigits int) (float64, bool) {
+	if len(value) < degreeDigits {
+		return 0, false
+	}
+
+	deg, err := strconv.Atoi(value[:degreeDigits])
+	if err != nil {
+		return 0, false
+	}
+	min, err := strconv.ParseFloat(value[degreeDigits:], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	coord := float64(deg) + min/60.0
+	switch hemisphere {
+	case "S", "W":
+		coord = -coord
+	case "N", "E":
+	default:
+		return 0, false
+	}
+
+	return coord, true
+}
+
+// validNmeaChecksum will check the NMEA sentence checksum.
+// The checksum is the XOR of all the characters between $ and *.
+// A sentence without a checksum is accepted.
+func validNmeaChecksum(sentence string) bool {
+	if len(sentence) < 2 || sentence[0] != '$' {
+		return false
+	}
+
+	star := strings.IndexByte(sentence, '*')
+	if star < 0 {
+		return true
+	}
+
+	expected, err := strconv.ParseUint(sentence[star+1:], 16, 8)
+	if err != nil {
+		return false
+	}
+
+	var checksum byte
+	for i := 1; i < star; i++ {
+		checksum ^= sentence[i]
+	}
+
+	return checksum == byte(expected)
+}
@@ -0,0 +1,129 @@
+package rti
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// StandardAtmosphere is the standard atmospheric pressure at sea level in Pascals.
+const StandardAtmosphere = 101325.0
+
+// pascalsPerDecibar is the number of Pascals in a decibar.
+const pascalsPerDecibar = 10000.0
+
+// TimeSeries is a series of values at each time.  The times must be in order.
+type TimeSeries struct {
+	Times  []time.Time // Time of each value
+	Values []float64   // Values
+}
+
+// At will linearly interpolate the value at the time.  If the time is
+// outside of the series, false is returned.
+func (ts *TimeSeries) At(t time.Time) (float64, bool) {
+	n := len(ts.Times)
+	if n == 0 || n != len(ts.Values) || t.Before(ts.Times[0]) || t.After(ts.Times[n-1]) {
+		return 0, false
+	}
+
+	// First time at or after t
+	i := sort.Search(n, func(i int) bool { return !ts.Times[i].Before(t) })
+	if ts.Times[i].Equal(t) {
+		return ts.Values[i], true
+	}
+
+	t0, t1 := ts.Times[i-1], ts.Times[i]
+	frac := float64(t.Sub(t0)) / float64(t1.Sub(t0))
+	return ts.Values[i-1] + frac*(ts.Values[i]-ts.Values[i-1]), true
+}
+
+// DepthOptions will set how the pressure is converted to depth.
+type DepthOptions struct {
+	Latitude          float64     // Latitude in degrees
+	Atmospheric       float64     // Atmospheric pressure in Pascals removed from the pressure
+	AtmosphericSeries *TimeSeries // Atmospheric pressure in Pascals at each time.  If set, used instead of Atmospheric
+	SensorElevation   float64     // Elevation of the pressure sensor above the datum in meters for the water level
+}
+
+// WaterLevel is the water level for an ensemble.
+type WaterLevel struct {
+	Time  time.Time // Ensemble time
+	Depth float64   // Depth of the pressure sensor in meters
+	Level float64   // Water surface elevation above the datum in meters
+}
+
+// PressureToDepth will convert the pressure in decibars to depth in meters
+// using the UNESCO (Fofonoff and Millard 1983) formula.  The latitude is in degrees.
+func PressureToDepth(pressure float64, latitude float64) float64 {
+	sinLat := math.Sin(latitude * (math.Pi / 180.0))
+	x := sinLat * sinLat
+
+	// Gravity at the latitude and pressure
+	gr := 9.780318*(1.0+(5.2788e-3+2.36e-5*x)*x) + 1.092e-6*pressure
+
+	return (((-1.82e-15*pressure+2.279e-10)*pressure-2.2512e-5)*pressure + 9.72659) * pressure / gr
+}
+
+// Depth will get the depth of the pressure sensor in meters.  The atmospheric
+// pressure is removed from the Ancillary Data Set pressure and the remaining
+// pressure is converted to depth using the latitude.
+func (ens *Ensemble) Depth(opts DepthOptions) (float64, error) {
+	pressure, err := ens.SeaPressure(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	return PressureToDepth(pressure, opts.Latitude), nil
+}
+
+// SeaPressure will get the pressure of the water above the pressure sensor in
+// decibars.  The atmospheric pressure is removed from the Ancillary Data Set pressure.
+func (ens *Ensemble) SeaPressure(opts DepthOptions) (float64, error) {
+	anc := ens.GetAncillaryData()
+	if IsBad(anc.Pressure) || anc.Pressure <= 0 {
+		return 0, errors.New("no pressure")
+	}
+
+	atmospheric := opts.Atmospheric
+	if opts.AtmosphericSeries != nil {
+		t, err := ens.GetEnsembleData().Time()
+		if err != nil {
+			return 0, err
+		}
+
+		var ok bool
+		if atmospheric, ok = opts.AtmosphericSeries.At(t); !ok {
+			return 0, errors.New("no atmospheric pressure at the ensemble time")
+		}
+	}
+
+	return (float64(anc.Pressure) - atmospheric) / pascalsPerDecibar, nil
+}
+
+// WaterLevels will create a water level time series from the pressure in
+// each ensemble.  The water level is the depth of the pressure sensor plus
+// the elevation of the sensor above the datum.  Ensembles without a valid
+// time or depth are skipped.
+func WaterLevels(ensembles []Ensemble, opts DepthOptions) []WaterLevel {
+	var levels []WaterLevel
+	for i := range ensembles {
+		t, err := ensembles[i].GetEnsembleData().Time()
+		if err != nil {
+			continue
+		}
+
+		depth, err := ensembles[i].Depth(opts)
+		if err != nil {
+			continue
+		}
+
+		levels = append(levels, WaterLevel{
+			Time:  t,
+			Depth: depth,
+			Level: opts.SensorElevation + depth,
+		})
+	}
+
+	return levels
+}
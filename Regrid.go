@@ -0,0 +1,194 @@
+package rti
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// VerticalAxis is the vertical coordinate of a grid.
+type VerticalAxis int
+
+// Vertical axes
+const (
+	AxisDepth             VerticalAxis = iota // Depth below the surface in meters
+	AxisHeightAboveBottom                     // Height above the bottom in meters
+)
+
+// InterpolationMethod is how the values are interpolated to the grid.
+type InterpolationMethod int
+
+// Interpolation methods
+const (
+	InterpolateLinear  InterpolationMethod = iota // Linear interpolation between the 2 nearest bins
+	InterpolateNearest                            // Value of the nearest bin
+)
+
+// Grid is a fixed vertical grid to resample the bins to.
+type Grid struct {
+	Axis   VerticalAxis        // Vertical coordinate of the levels
+	Levels []float64           // Grid levels in meters
+	Method InterpolationMethod // Interpolation method
+
+	InstrumentHeight float64 // Height of the transducer above the bottom in meters for an upward looking ADCP
+	WaterDepth       float64 // Water depth in meters for a downward looking ADCP without bottom track
+}
+
+// GriddedProfile is the ensemble data resampled to the grid levels.
+// Each matrix has a row for each level instead of each bin.
+type GriddedProfile struct {
+	Time           time.Time    // Ensemble time
+	EnsembleNumber uint32       // Ensemble number
+	Axis           VerticalAxis // Vertical coordinate of the levels
+	Levels         []float64    // Grid levels in meters
+
+	BeamVelocity       BinBeamMatrix[float32] // Beam velocity in m/s
+	InstrumentVelocity BinBeamMatrix[float32] // Instrument velocity in m/s
+	EarthVelocity      BinBeamMatrix[float32] // Earth velocity in m/s
+	Amplitude          BinBeamMatrix[float32] // Amplitude in dB
+	Correlation        BinBeamMatrix[float32] // Correlation
+}
+
+// Regrid will resample the bins of the ensemble to the grid levels.  The position
+// of each bin is computed from the bin depths.  A level between 2 bins is bad if
+// either bin is bad for linear interpolation.  A level outside of the bins is bad.
+func (grid *Grid) Regrid(ens *Ensemble) (GriddedProfile, error) {
+	positions, err := grid.binPositions(ens)
+	if err != nil {
+		return GriddedProfile{}, err
+	}
+
+	profile := GriddedProfile{
+		EnsembleNumber: ens.GetEnsembleData().EnsembleNumber,
+		Axis:           grid.Axis,
+		Levels:         grid.Levels,
+	}
+	if t, err := ens.GetEnsembleData().Time(); err == nil {
+		profile.Time = t
+	}
+
+	beam := ens.GetBeamVelocityData()
+	inst := ens.GetInstrumentVelocityData()
+	earth := ens.GetEarthVelocityData()
+	amp := ens.GetAmplitudeData()
+	corr := ens.GetCorrelationData()
+
+	profile.BeamVelocity = grid.resample(beam.Velocity, positions, beam.Base.badValue())
+	profile.InstrumentVelocity = grid.resample(inst.Velocity, positions, inst.Base.badValue())
+	profile.EarthVelocity = grid.resample(earth.Velocity, positions, earth.Base.badValue())
+	profile.Amplitude = grid.resample(amp.Amplitude, positions, amp.Base.badValue())
+	profile.Correlation = grid.resample(corr.Correlation, positions, corr.Base.badValue())
+
+	return profile, nil
+}
+
+// binPositions will get the position of each bin on the grid axis.
+func (grid *Grid) binPositions(ens *Ensemble) ([]float64, error) {
+	anc := ens.GetAncillaryData()
+	depths := ens.BinDepths()
+	positions := make([]float64, len(depths))
+
+	if grid.Axis == AxisDepth {
+		for bin, d := range depths {
+			positions[bin] = float64(d)
+		}
+		return positions, nil
+	}
+
+	// Height above bottom
+	ranges := ens.BinRanges()
+	if anc.IsUpwardLooking() {
+		if grid.InstrumentHeight <= 0 {
+			return nil, errors.New("instrument height is needed for the height above bottom of an upward looking ADCP")
+		}
+		for bin, r := range ranges {
+			positions[bin] = grid.InstrumentHeight + float64(r)
+		}
+		return positions, nil
+	}
+
+	var bottom float64
+	if r, ok := ens.GetBottomTrackData().MinRange(); ok {
+		bottom = float64(r)
+	} else if grid.WaterDepth > 0 {
+		bottom = grid.WaterDepth - float64(anc.TransducerDepth)
+	} else {
+		return nil, errors.New("no bottom track range or water depth for the height above bottom")
+	}
+
+	for bin, r := range ranges {
+		positions[bin] = bottom - float64(r)
+	}
+
+	return positions, nil
+}
+
+// resample will resample each beam of the matrix to the grid levels.
+// The bad levels are set to the bad value of the dataset.
+func (grid *Grid) resample(mat BinBeamMatrix[float32], positions []float64, bad float32) BinBeamMatrix[float32] {
+	result := NewBinBeamMatrix[float32](len(grid.Levels), mat.NumBeams)
+	numBins := mat.NumBins
+	if len(positions) < numBins {
+		numBins = len(positions)
+	}
+
+	for level, pos := range grid.Levels {
+		for beam := 0; beam < mat.NumBeams; beam++ {
+			result.Set(level, beam, grid.interpolate(mat, positions[:numBins], beam, pos, bad))
+		}
+	}
+
+	return result
+}
+
+// interpolate will get the value of the beam at the position.
+// The bin positions can be increasing or decreasing.
+func (grid *Grid) interpolate(mat BinBeamMatrix[float32], positions []float64, beam int, pos float64, bad float32) float32 {
+	if len(positions) == 0 {
+		return bad
+	}
+
+	// Single bin
+	if len(positions) == 1 {
+		if math.Abs(pos-positions[0]) < 1e-6 {
+			return mat.At(0, beam)
+		}
+		return bad
+	}
+
+	for bin := 0; bin < len(positions)-1; bin++ {
+		p0, p1 := positions[bin], positions[bin+1]
+		if (pos < p0 || pos > p1) && (pos > p0 || pos < p1) {
+			continue
+		}
+
+		v0, v1 := mat.At(bin, beam), mat.At(bin+1, beam)
+		frac := 0.0
+		if p1 != p0 {
+			frac = (pos - p0) / (p1 - p0)
+		}
+
+		if grid.Method == InterpolateNearest {
+			if frac <= 0.5 {
+				return v0
+			}
+			return v1
+		}
+
+		// Level is at a bin
+		if frac == 0 {
+			return v0
+		}
+		if frac == 1 {
+			return v1
+		}
+
+		if IsBad(v0) || IsBad(v1) {
+			return bad
+		}
+
+		return float32(float64(v0) + frac*(float64(v1)-float64(v0)))
+	}
+
+	return bad
+}
@@ -0,0 +1,338 @@
+package rti
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// FillType is how an ensemble on the regular time grid was created.
+type FillType int
+
+// Fill types
+const (
+	FillNone         FillType = iota // Ensemble was interpolated from ensembles with no gap between them
+	FillInterpolated                 // Ensemble was interpolated across a short gap
+	FillEmpty                        // Ensemble is in a long gap and all the values are bad
+)
+
+// Gap is a gap in the recorded ensembles.
+type Gap struct {
+	Start        time.Time     // Time of the last ensemble before the gap
+	End          time.Time     // Time of the first ensemble after the gap
+	Duration     time.Duration // Time between the ensembles
+	FirstMissing uint32        // First missing ensemble number
+	LastMissing  uint32        // Last missing ensemble number
+	Filled       bool          // Gap was filled by interpolation
+}
+
+// GapReport lists all the gaps found while resampling.
+type GapReport struct {
+	Gaps           []Gap // Gaps in time order
+	MissingNumbers int   // Total number of missing ensemble numbers
+	EmptyEnsembles int   // Number of empty ensembles inserted in long gaps
+}
+
+// Resampler will put the ensembles on a regular time grid.  Each ensemble on
+// the grid is interpolated from the recorded ensembles before and after it.
+// If the recorded ensembles are more than MaxGap apart, empty ensembles with
+// all the values bad are inserted instead.
+type Resampler struct {
+	Interval time.Duration // Time between the ensembles on the grid
+	MaxGap   time.Duration // Longest gap to interpolate across.  If 0, 1.5 times the interval is used
+	Vector   bool          // Interpolate the horizontal Earth velocity as speed and direction instead of East and North
+}
+
+// Resample will put the ensembles on the regular time grid.  The ensembles are
+// sorted by time and ensembles without a valid time are not used.  The grid
+// starts at the first ensemble time rounded up to the interval.
+func (rs *Resampler) Resample(ensembles []Ensemble) ([]Ensemble, GapReport) {
+	var report GapReport
+	if rs.Interval <= 0 {
+		return nil, report
+	}
+
+	// Ensembles with a valid time in order
+	type timed struct {
+		t   time.Time
+		ens *Ensemble
+	}
+	var recs []timed
+	for i := range ensembles {
+		if t, err := ensembles[i].GetEnsembleData().Time(); err == nil {
+			recs = append(recs, timed{t, &ensembles[i]})
+		}
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].t.Before(recs[j].t) })
+	if len(recs) == 0 {
+		return nil, report
+	}
+
+	// Gaps
+	gapThreshold := rs.Interval + rs.Interval/2
+	maxGap := rs.MaxGap
+	if maxGap <= 0 {
+		maxGap = gapThreshold
+	}
+	for i := 1; i < len(recs); i++ {
+		prev, next := recs[i-1], recs[i]
+		span := next.t.Sub(prev.t)
+		prevNum := prev.ens.GetEnsembleData().EnsembleNumber
+		nextNum := next.ens.GetEnsembleData().EnsembleNumber
+
+		if span <= gapThreshold && nextNum <= prevNum+1 {
+			continue
+		}
+
+		gap := Gap{
+			Start:    prev.t,
+			End:      next.t,
+			Duration: span,
+			Filled:   span <= maxGap,
+		}
+		if nextNum > prevNum+1 {
+			gap.FirstMissing = prevNum + 1
+			gap.LastMissing = nextNum - 1
+			report.MissingNumbers += int(nextNum - prevNum - 1)
+		}
+		report.Gaps = append(report.Gaps, gap)
+	}
+
+	// Grid
+	var result []Ensemble
+	start := recs[0].t.Truncate(rs.Interval)
+	if start.Before(recs[0].t) {
+		start = start.Add(rs.Interval)
+	}
+
+	next := 0
+	for t := start; !t.After(recs[len(recs)-1].t); t = t.Add(rs.Interval) {
+		// Recorded ensembles before and after the grid time
+		for next < len(recs)-1 && !recs[next+1].t.After(t) {
+			next++
+		}
+		a := recs[next]
+		b := a
+		if next < len(recs)-1 && !a.t.Equal(t) {
+			b = recs[next+1]
+		}
+
+		span := b.t.Sub(a.t)
+		var ens Ensemble
+		switch {
+		case a.t.Equal(t) || span == 0:
+			ens = copyEnsemble(a.ens)
+			ens.Fill = FillNone
+		case span <= maxGap:
+			frac := float64(t.Sub(a.t)) / float64(span)
+			ens = interpolateEnsembles(a.ens, b.ens, frac, rs.Vector)
+			ens.Fill = FillNone
+			if span > gapThreshold {
+				ens.Fill = FillInterpolated
+			}
+		default:
+			ens = emptyEnsemble(a.ens)
+			ens.Fill = FillEmpty
+			report.EmptyEnsembles++
+		}
+
+		setEnsembleTime(ens.GetEnsembleData(), t)
+		result = append(result, ens)
+	}
+
+	return result, report
+}
+
+// copyEnsemble will create a decoded copy of the ensemble that does
+// not share any data with the ensemble.
+func copyEnsemble(ens *Ensemble) Ensemble {
+	cp := AverageEnsembles([]Ensemble{*ens})
+	cp.Average = nil
+	cp.ScreenReasons = append([]ScreenReason(nil), ens.ScreenReasons...)
+
+	return cp
+}
+
+// emptyEnsemble will create a copy of the ensemble with all the values bad.
+func emptyEnsemble(ens *Ensemble) Ensemble {
+	empty := copyEnsemble(ens)
+
+	for _, ds := range []struct {
+		base *BaseDataSet
+		mat  BinBeamMatrix[float32]
+	}{
+		{&empty.BeamVelocityData.Base, empty.BeamVelocityData.Velocity},
+		{&empty.InstrumentVelocityData.Base, empty.InstrumentVelocityData.Velocity},
+		{&empty.EarthVelocityData.Base, empty.EarthVelocityData.Velocity},
+		{&empty.AmplitudeData.Base, empty.AmplitudeData.Amplitude},
+		{&empty.CorrelationData.Base, empty.CorrelationData.Correlation},
+	} {
+		bad := ds.base.badValue()
+		for i := range ds.mat.Data {
+			ds.mat.Data[i] = bad
+		}
+	}
+	for _, mat := range []BinBeamMatrix[int32]{empty.GoodBeamData.GoodPings, empty.GoodEarthData.GoodPings} {
+		for i := range mat.Data {
+			mat.Data[i] = 0
+		}
+	}
+	empty.EarthVelocityData.calcVectors()
+
+	empty.BottomTrackData = BottomTrackDataSet{Base: empty.BottomTrackData.Base}
+	empty.NmeaData = NmeaDataSet{Base: empty.NmeaData.Base}
+	empty.VerticalBeamData = nil
+	empty.EnsembleData.ActualPingCount = 0
+
+	return empty
+}
+
+// interpolateEnsembles will interpolate between ensemble a and b.  The fraction
+// is the distance from a to b.  A value is bad if it is bad in either ensemble.
+// If vector is set, the horizontal Earth velocity is interpolated as speed and direction.
+func interpolateEnsembles(a *Ensemble, b *Ensemble, frac float64, vector bool) Ensemble {
+	ens := copyEnsemble(a)
+
+	// Ancillary Data Set
+	ancA, ancB := &ens.AncillaryData, b.GetAncillaryData()
+	ancA.FirstBinRange = interpolateValue(ancA.FirstBinRange, ancB.FirstBinRange, frac)
+	ancA.BinSize = interpolateValue(ancA.BinSize, ancB.BinSize, frac)
+	ancA.Heading = interpolateAngle(ancA.Heading, ancB.Heading, frac)
+	ancA.Pitch = interpolateValue(ancA.Pitch, ancB.Pitch, frac)
+	ancA.Roll = signedAngle(interpolateAngle(ancA.Roll, ancB.Roll, frac))
+	ancA.WaterTemp = interpolateValue(ancA.WaterTemp, ancB.WaterTemp, frac)
+	ancA.SystemTemp = interpolateValue(ancA.SystemTemp, ancB.SystemTemp, frac)
+	ancA.Salinity = interpolateValue(ancA.Salinity, ancB.Salinity, frac)
+	ancA.Pressure = interpolateValue(ancA.Pressure, ancB.Pressure, frac)
+	ancA.TransducerDepth = interpolateValue(ancA.TransducerDepth, ancB.TransducerDepth, frac)
+	ancA.SpeedOfSound = interpolateValue(ancA.SpeedOfSound, ancB.SpeedOfSound, frac)
+
+	// Bin datasets
+	interpolateMatrix(ens.BeamVelocityData.Velocity, b.GetBeamVelocityData().Velocity, frac)
+	interpolateMatrix(ens.InstrumentVelocityData.Velocity, b.GetInstrumentVelocityData().Velocity, frac)
+	interpolateMatrix(ens.AmplitudeData.Amplitude, b.GetAmplitudeData().Amplitude, frac)
+	interpolateMatrix(ens.CorrelationData.Correlation, b.GetCorrelationData().Correlation, frac)
+
+	earthA, earthB := ens.EarthVelocityData.Velocity, b.GetEarthVelocityData().Velocity
+	if vector {
+		interpolateVectors(earthA, earthB, frac)
+	} else {
+		interpolateMatrix(earthA, earthB, frac)
+	}
+	ens.EarthVelocityData.calcVectors()
+
+	// Bottom Track Data Set
+	btA, btB := &ens.BottomTrackData, b.GetBottomTrackData()
+	interpolateSlice(btA.Range, btB.Range, frac)
+	interpolateSlice(btA.BeamVelocity, btB.BeamVelocity, frac)
+	interpolateSlice(btA.InstrumentVelocity, btB.InstrumentVelocity, frac)
+	interpolateSlice(btA.EarthVelocity, btB.EarthVelocity, frac)
+
+	return ens
+}
+
+// interpolateValue will interpolate between a and b.
+// If either value is bad, the bad value is returned.
+func interpolateValue(a float32, b float32, frac float64) float32 {
+	if IsBad(a) {
+		return a
+	}
+	if IsBad(b) {
+		return badLike(b)
+	}
+
+	return float32(float64(a) + frac*(float64(b)-float64(a)))
+}
+
+// interpolateAngle will interpolate the angle in degrees the short way around.
+func interpolateAngle(a float32, b float32, frac float64) float32 {
+	if IsBad(a) {
+		return a
+	}
+	if IsBad(b) {
+		return badLike(b)
+	}
+
+	diff := math.Mod(float64(b)-float64(a)+540.0, 360.0) - 180.0
+	angle := math.Mod(float64(a)+frac*diff+360.0, 360.0)
+
+	return float32(angle)
+}
+
+// interpolateMatrix will interpolate each value of a toward b in place.
+// If the matrices are different sizes, a is not changed.
+func interpolateMatrix(a BinBeamMatrix[float32], b BinBeamMatrix[float32], frac float64) {
+	if a.NumBins != b.NumBins || a.NumBeams != b.NumBeams {
+		return
+	}
+
+	interpolateSlice(a.Data, b.Data, frac)
+}
+
+// interpolateSlice will interpolate each value of a toward b in place.
+// If the slices are different lengths, a is not changed.
+func interpolateSlice(a []float32, b []float32, frac float64) {
+	if len(a) != len(b) {
+		return
+	}
+
+	for i := range a {
+		a[i] = interpolateValue(a[i], b[i], frac)
+	}
+}
+
+// interpolateVectors will interpolate the horizontal Earth velocities of a toward b
+// in place as speed and direction.  The other components are interpolated linearly.
+func interpolateVectors(a BinBeamMatrix[float32], b BinBeamMatrix[float32], frac float64) {
+	if a.NumBins != b.NumBins || a.NumBeams != b.NumBeams || a.NumBeams <= EarthNorth {
+		return
+	}
+
+	for bin := 0; bin < a.NumBins; bin++ {
+		velA, velB := a.Bin(bin), b.Bin(bin)
+		east, north := velA[EarthEast], velA[EarthNorth]
+
+		for i := EarthNorth + 1; i < len(velA); i++ {
+			velA[i] = interpolateValue(velA[i], velB[i], frac)
+		}
+
+		if IsBad(east) || IsBad(north) || IsBad(velB[EarthEast]) || IsBad(velB[EarthNorth]) {
+			bad := float32(BadVelocity)
+			for _, v := range []float32{east, north, velB[EarthEast], velB[EarthNorth]} {
+				if math.IsNaN(float64(v)) {
+					bad = v
+				}
+			}
+			velA[EarthEast], velA[EarthNorth] = bad, bad
+			continue
+		}
+
+		speedA := math.Hypot(float64(east), float64(north))
+		speedB := math.Hypot(float64(velB[EarthEast]), float64(velB[EarthNorth]))
+		dirA := float32(math.Atan2(float64(east), float64(north)) * (180.0 / math.Pi))
+		dirB := float32(math.Atan2(float64(velB[EarthEast]), float64(velB[EarthNorth])) * (180.0 / math.Pi))
+
+		speed := speedA + frac*(speedB-speedA)
+		sin, cos := math.Sincos(float64(interpolateAngle(dirA, dirB, frac)) * (math.Pi / 180.0))
+		velA[EarthEast] = float32(speed * sin)
+		velA[EarthNorth] = float32(speed * cos)
+	}
+}
+
+// setEnsembleTime will set the date and time of the ensemble.
+// The time is set in the instrument time zone.
+func setEnsembleTime(ensData *EnsembleDataSet, t time.Time) {
+	loc := ensData.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	ensData.Year = uint32(t.Year())
+	ensData.Month = uint32(t.Month())
+	ensData.Day = uint32(t.Day())
+	ensData.Hour = uint32(t.Hour())
+	ensData.Minute = uint32(t.Minute())
+	ensData.Second = uint32(t.Second())
+	ensData.HSec = uint32(t.Nanosecond() / 10000000)
+}
@@ -0,0 +1,137 @@
+package rti
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// resampleStart is the time of the first recorded ensemble in the resample tests.
+var resampleStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTimedEnsemble will create an ensemble with the ensemble number, time in
+// seconds from resampleStart, water temperature and roll.
+func newTimedEnsemble(num uint32, seconds float64, temp float32, roll float32) Ensemble {
+	var ens Ensemble
+	ens.EnsembleData.EnsembleNumber = num
+	setEnsembleTime(&ens.EnsembleData, resampleStart.Add(time.Duration(seconds*float64(time.Second))))
+	ens.AncillaryData.WaterTemp = temp
+	ens.AncillaryData.Roll = roll
+
+	return ens
+}
+
+// TestResampleJitter will check jittered ensemble times are not reported as gaps
+// with the default MaxGap.
+func TestResampleJitter(t *testing.T) {
+	ensembles := []Ensemble{
+		newTimedEnsemble(1, 0, 10, 0),
+		newTimedEnsemble(2, 1.1, 11, 0),
+		newTimedEnsemble(3, 1.9, 12, 0),
+		newTimedEnsemble(4, 3.2, 13, 0),
+		newTimedEnsemble(5, 4, 14, 0),
+	}
+
+	rs := Resampler{Interval: time.Second}
+	result, report := rs.Resample(ensembles)
+
+	if len(result) != 5 {
+		t.Fatalf("%d ensembles, want 5", len(result))
+	}
+	if len(report.Gaps) != 0 || report.EmptyEnsembles != 0 {
+		t.Errorf("report = %+v, want no gaps", report)
+	}
+
+	// Grid times are exact and the values are interpolated
+	want := []float32{10, 10 + 1.0/1.1, 12 + 0.1/1.3, 12 + 1.1/1.3, 14}
+	for i, ens := range result {
+		if ens.Fill != FillNone {
+			t.Errorf("ensemble %d fill = %d, want FillNone", i, ens.Fill)
+		}
+		if got, _ := ens.GetEnsembleData().Time(); !got.Equal(resampleStart.Add(time.Duration(i) * time.Second)) {
+			t.Errorf("ensemble %d time = %v", i, got)
+		}
+		if got := ens.AncillaryData.WaterTemp; math.Abs(float64(got-want[i])) > 1e-4 {
+			t.Errorf("ensemble %d water temperature = %g, want %g", i, got, want[i])
+		}
+	}
+}
+
+// TestResampleGap will check the gap report and the long and short gap fill.
+func TestResampleGap(t *testing.T) {
+	ensembles := []Ensemble{
+		newTimedEnsemble(1, 0, 10, 0),
+		newTimedEnsemble(2, 1, 11, 0),
+		newTimedEnsemble(3, 2, 12, 0),
+		newTimedEnsemble(6, 5, 18, 0),
+	}
+
+	tests := []struct {
+		maxGap time.Duration
+		fill   FillType
+		empty  int
+		temp   float32 // Water temperature at 3 seconds.  An empty ensemble keeps the ancillary data before the gap
+	}{
+		{0, FillEmpty, 2, 12},
+		{5 * time.Second, FillInterpolated, 0, 14},
+	}
+
+	for _, test := range tests {
+		rs := Resampler{Interval: time.Second, MaxGap: test.maxGap}
+		result, report := rs.Resample(ensembles)
+
+		if len(result) != 6 {
+			t.Fatalf("MaxGap %v: %d ensembles, want 6", test.maxGap, len(result))
+		}
+		if len(report.Gaps) != 1 {
+			t.Fatalf("MaxGap %v: %d gaps, want 1", test.maxGap, len(report.Gaps))
+		}
+
+		gap := report.Gaps[0]
+		if gap.FirstMissing != 4 || gap.LastMissing != 5 || gap.Duration != 3*time.Second || report.MissingNumbers != 2 {
+			t.Errorf("MaxGap %v: gap = %+v missing %d, want 4 to 5 over 3s", test.maxGap, gap, report.MissingNumbers)
+		}
+		if gap.Filled != (test.fill == FillInterpolated) || report.EmptyEnsembles != test.empty {
+			t.Errorf("MaxGap %v: filled %v with %d empty ensembles, want %d empty", test.maxGap, gap.Filled, report.EmptyEnsembles, test.empty)
+		}
+
+		for _, i := range []int{3, 4} {
+			if result[i].Fill != test.fill {
+				t.Errorf("MaxGap %v: ensemble %d fill = %d, want %d", test.maxGap, i, result[i].Fill, test.fill)
+			}
+		}
+		if got := result[3].AncillaryData.WaterTemp; math.Abs(float64(got-test.temp)) > 1e-4 {
+			t.Errorf("MaxGap %v: water temperature = %g, want %g", test.maxGap, got, test.temp)
+		}
+	}
+}
+
+// TestInterpolateRoll will check the roll of an upward looking ADCP is
+// interpolated across +/- 180 degrees.
+func TestInterpolateRoll(t *testing.T) {
+	tests := []struct {
+		a, b float32
+		frac float64
+		want float32
+	}{
+		{-10, 10, 0.5, 0},
+		{170, -170, 0.5, 180},
+		{178, -174, 0.25, -180},
+		{178, -174, 0.75, -176},
+	}
+
+	for _, test := range tests {
+		got := signedAngle(interpolateAngle(test.a, test.b, test.frac))
+		if d := math.Mod(float64(got-test.want)+540, 360) - 180; math.Abs(d) > 1e-3 {
+			t.Errorf("roll from %g to %g at %g = %g, want %g", test.a, test.b, test.frac, got, test.want)
+		}
+	}
+
+	// Roll in a resampled ensemble
+	ensembles := []Ensemble{newTimedEnsemble(1, 0, 10, 179), newTimedEnsemble(2, 2, 10, -179)}
+	rs := Resampler{Interval: time.Second, MaxGap: 5 * time.Second}
+	result, _ := rs.Resample(ensembles)
+	if len(result) != 3 || math.Abs(math.Abs(float64(result[1].AncillaryData.Roll))-180) > 1e-3 {
+		t.Errorf("resampled roll = %v, want +/-180", result[1].AncillaryData.Roll)
+	}
+}
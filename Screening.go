@@ -0,0 +1,362 @@
+package rti
+
+import (
+	"math"
+	"strings"
+)
+
+// ScreenReason is the reason a bin was marked bad by a Filter.
+// Each bit is a different reason, so a bin can have multiple reasons.
+type ScreenReason uint32
+
+// Screening reasons
+const (
+	ScreenGood          ScreenReason = 0x00 // Bin passed all the filters
+	ScreenCorrelation   ScreenReason = 0x01 // Correlation below the threshold
+	ScreenAmplitude     ScreenReason = 0x02 // Amplitude or SNR below the threshold
+	ScreenErrorVelocity ScreenReason = 0x04 // Error velocity above the threshold
+	ScreenPercentGood   ScreenReason = 0x08 // Percent good pings below the threshold
+	ScreenMaxSpeed      ScreenReason = 0x10 // Water speed above the threshold
+	ScreenFish          ScreenReason = 0x20 // Echo intensity spike from a fish
+	ScreenSideLobe      ScreenReason = 0x40 // Side lobe contamination near the bottom or surface
+	ScreenNoReference   ScreenReason = 0x80 // No bottom track or GPS reference to remove the boat motion
+)
+
+// screenReasons is the name of each screening reason.
+var screenReasons = []struct {
+	reason ScreenReason
+	name   string
+}{
+	{ScreenCorrelation, "Correlation"},
+	{ScreenAmplitude, "Amplitude"},
+	{ScreenErrorVelocity, "Error Velocity"},
+	{ScreenPercentGood, "Percent Good"},
+	{ScreenMaxSpeed, "Max Speed"},
+	{ScreenFish, "Fish"},
+	{ScreenSideLobe, "Side Lobe"},
+	{ScreenNoReference, "No Reference"},
+}
+
+// String will list all the screening reasons.
+func (reason ScreenReason) String() string {
+	if reason == ScreenGood {
+		return "Good"
+	}
+
+	var names []string
+	for _, sr := range screenReasons {
+		if reason&sr.reason != 0 {
+			names = append(names, sr.name)
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// Filter will screen the ensemble and mark the bins that fail bad in place.
+type Filter interface {
+	Screen(ens *Ensemble)
+}
+
+// Pipeline is a chain of filters.  Each filter is run on the ensemble in order.
+type Pipeline []Filter
+
+// Screen will run each filter in the pipeline on the ensemble.
+func (pipeline Pipeline) Screen(ens *Ensemble) {
+	for _, filter := range pipeline {
+		filter.Screen(ens)
+	}
+}
+
+// MarkBinBad will mark all the velocities in the bin bad in the Beam,
+// Instrument and Earth Velocity Data Sets.  The reason is added to the
+// screening reasons for the bin.
+func (ens *Ensemble) MarkBinBad(bin int, reason ScreenReason) {
+	if !ens.addScreenReason(bin, reason) {
+		return
+	}
+
+	beam := ens.GetBeamVelocityData()
+	markBad(&beam.Base, beam.Velocity, bin)
+
+	inst := ens.GetInstrumentVelocityData()
+	markBad(&inst.Base, inst.Velocity, bin)
+
+	earth := ens.GetEarthVelocityData()
+	markBad(&earth.Base, earth.Velocity, bin)
+	if bin < len(earth.Vectors) {
+		earth.Vectors[bin] = calcVV(earth.Velocity.Bin(bin), earth.Base.badValue())
+	}
+}
+
+// addScreenReason will add the reason to the screening reasons for the bin.
+// If the bin does not exist, false is returned.
+func (ens *Ensemble) addScreenReason(bin int, reason ScreenReason) bool {
+	numBins := ens.numBins()
+	if bin < 0 || bin >= numBins {
+		return false
+	}
+
+	// Screening reasons for each bin
+	if len(ens.ScreenReasons) < numBins {
+		reasons := make([]ScreenReason, numBins)
+		copy(reasons, ens.ScreenReasons)
+		ens.ScreenReasons = reasons
+	}
+	ens.ScreenReasons[bin] |= reason
+
+	return true
+}
+
+// markBad will set all the values in the bin bad.
+func markBad(base *BaseDataSet, mat BinBeamMatrix[float32], bin int) {
+	if bin >= mat.NumBins {
+		return
+	}
+
+	bad := base.badValue()
+	for beam := range mat.Bin(bin) {
+		mat.Set(bin, beam, bad)
+	}
+}
+
+// numBins will get the number of bins in the ensemble.
+func (ens *Ensemble) numBins() int {
+	numBins := int(ens.GetEnsembleData().NumBins)
+	for _, base := range []*BaseDataSet{&ens.BeamVelocityData.Base, &ens.InstrumentVelocityData.Base, &ens.EarthVelocityData.Base} {
+		if int(base.NumElements) > numBins {
+			numBins = int(base.NumElements)
+		}
+	}
+
+	return numBins
+}
+
+// CorrelationFilter will mark a bin bad if the correlation of any beam
+// is below the minimum.  The minimum is in the same units as the
+// Correlation Data Set.
+type CorrelationFilter struct {
+	Min float32 // Minimum correlation
+}
+
+// Screen will screen the ensemble.
+func (filter CorrelationFilter) Screen(ens *Ensemble) {
+	corr := ens.GetCorrelationData().Correlation
+	for bin := 0; bin < corr.NumBins; bin++ {
+		for _, v := range corr.Bin(bin) {
+			if !IsBad(v) && v < filter.Min {
+				ens.MarkBinBad(bin, ScreenCorrelation)
+				break
+			}
+		}
+	}
+}
+
+// AmplitudeFilter will mark a bin bad if the amplitude of any beam is below
+// the minimum in dB.  If the noise floor is given, the signal to noise ratio
+// (amplitude - noise floor) is compared to the minimum.
+type AmplitudeFilter struct {
+	Min        float32 // Minimum amplitude or SNR in dB
+	NoiseFloor float32 // Noise floor in dB
+}
+
+// Screen will screen the ensemble.
+func (filter AmplitudeFilter) Screen(ens *Ensemble) {
+	amp := ens.GetAmplitudeData().Amplitude
+	for bin := 0; bin < amp.NumBins; bin++ {
+		for _, v := range amp.Bin(bin) {
+			if !IsBad(v) && v-filter.NoiseFloor < filter.Min {
+				ens.MarkBinBad(bin, ScreenAmplitude)
+				break
+			}
+		}
+	}
+}
+
+// ErrorVelocityFilter will mark a bin bad if the absolute error velocity
+// is above the maximum in m/s.  The error velocity is the fourth component
+// of the Instrument Velocity Data Set.  If there is no Instrument Velocity
+// Data Set, the Earth Velocity Data Set error velocity is used.
+type ErrorVelocityFilter struct {
+	Max float32 // Maximum error velocity in m/s
+}
+
+// Screen will screen the ensemble.
+func (filter ErrorVelocityFilter) Screen(ens *Ensemble) {
+	vel := ens.GetInstrumentVelocityData().Velocity
+	if vel.NumBeams <= InstrumentError {
+		vel = ens.GetEarthVelocityData().Velocity
+	}
+	if vel.NumBeams <= InstrumentError {
+		return
+	}
+
+	for bin := 0; bin < vel.NumBins; bin++ {
+		v := vel.At(bin, InstrumentError)
+		if !IsBad(v) && float32(math.Abs(float64(v))) > filter.Max {
+			ens.MarkBinBad(bin, ScreenErrorVelocity)
+		}
+	}
+}
+
+// PercentGoodFilter will mark a bin bad if the percent of good pings for
+// any beam is below the minimum.  The percent good is the Good Beam Data Set
+// divided by the actual ping count.
+type PercentGoodFilter struct {
+	Min float32 // Minimum percent good pings (0-100)
+}
+
+// Screen will screen the ensemble.
+func (filter PercentGoodFilter) Screen(ens *Ensemble) {
+	pings := ens.GetEnsembleData().ActualPingCount
+	if pings == 0 {
+		return
+	}
+
+	good := ens.GetGoodBeamData().GoodPings
+	for bin := 0; bin < good.NumBins; bin++ {
+		for _, v := range good.Bin(bin) {
+			if float32(v)*100/float32(pings) < filter.Min {
+				ens.MarkBinBad(bin, ScreenPercentGood)
+				break
+			}
+		}
+	}
+}
+
+// MaxSpeedFilter will mark a bin bad if the horizontal water speed
+// is above the maximum in m/s.
+type MaxSpeedFilter struct {
+	Max float32 // Maximum water speed in m/s
+}
+
+// Screen will screen the ensemble.
+func (filter MaxSpeedFilter) Screen(ens *Ensemble) {
+	earth := ens.GetEarthVelocityData()
+	if earth.Velocity.NumBeams <= EarthNorth {
+		return
+	}
+
+	for bin := 0; bin < earth.Velocity.NumBins; bin++ {
+		east := earth.Velocity.At(bin, EarthEast)
+		north := earth.Velocity.At(bin, EarthNorth)
+		if IsBad(east) || IsBad(north) {
+			continue
+		}
+
+		if math.Hypot(float64(east), float64(north)) > float64(filter.Max) {
+			ens.MarkBinBad(bin, ScreenMaxSpeed)
+		}
+	}
+}
+
+// FishFilter will mark a bin bad if a fish is detected in the echo intensity.
+// A fish is in one beam at a time, so the amplitude of that beam is much higher
+// than the other beams.  If the difference between the highest and lowest beam
+// amplitude is above the maximum in dB, the bin is bad.
+type FishFilter struct {
+	MaxDifference float32 // Maximum difference between the beam amplitudes in dB
+}
+
+// Screen will screen the ensemble.
+func (filter FishFilter) Screen(ens *Ensemble) {
+	amp := ens.GetAmplitudeData().Amplitude
+	if amp.NumBeams < 2 {
+		return
+	}
+
+	for bin := 0; bin < amp.NumBins; bin++ {
+		min := float32(math.MaxFloat32)
+		max := float32(-math.MaxFloat32)
+		for _, v := range amp.Bin(bin) {
+			if IsBad(v) {
+				continue
+			}
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+
+		if max > min && max-min > filter.MaxDifference {
+			ens.MarkBinBad(bin, ScreenFish)
+		}
+	}
+}
+
+// SideLobeFilter will mark the bins bad that are contaminated by the side lobe
+// reflection off the bottom or the surface.  The side lobe reaches the boundary
+// before the main beam, so the bins beyond D cos(beam angle) - bin size are bad.
+// D is the distance from the transducer to the boundary.  A downward looking ADCP
+// uses the bottom track range, or the water depth less the transducer depth.
+// An upward looking ADCP uses the depth from the pressure sensor, or the
+// transducer depth if there is no pressure.
+type SideLobeFilter struct {
+	BeamAngle  float64      // Beam angle in degrees.  If 0, the subsystem beam angle is used
+	WaterDepth float32      // Water depth in meters used when there is no bottom track
+	Pressure   DepthOptions // Conversion of the pressure to the transducer depth
+}
+
+// Screen will screen the ensemble.
+func (filter SideLobeFilter) Screen(ens *Ensemble) {
+	anc := ens.GetAncillaryData()
+
+	cutoff, ok := filter.cutoff(ens)
+	if !ok {
+		return
+	}
+
+	for bin := 0; bin < ens.numBins(); bin++ {
+		binRange := float64(anc.FirstBinRange) + float64(bin)*float64(anc.BinSize)
+		if binRange > cutoff {
+			ens.MarkBinBad(bin, ScreenSideLobe)
+		}
+	}
+}
+
+// cutoff will get the range from the transducer in meters beyond which
+// the bins are contaminated by the side lobe.
+func (filter SideLobeFilter) cutoff(ens *Ensemble) (float64, bool) {
+	anc := ens.GetAncillaryData()
+
+	distance, ok := filter.boundaryDistance(ens)
+	if !ok || anc.BinSize <= 0 {
+		return 0, false
+	}
+
+	angle := filter.BeamAngle
+	if angle == 0 {
+		angle = Subsystem(ens.GetEnsembleData().Firmware.SubsystemCode).BeamAngle()
+	}
+
+	return float64(distance)*math.Cos(angle*math.Pi/180.0) - float64(anc.BinSize), true
+}
+
+// boundaryDistance will get the distance from the transducer to the
+// bottom or the surface in meters.
+func (filter SideLobeFilter) boundaryDistance(ens *Ensemble) (float32, bool) {
+	anc := ens.GetAncillaryData()
+
+	// Transducer depth
+	depth := anc.TransducerDepth
+	if d, err := ens.Depth(filter.Pressure); err == nil {
+		depth = float32(d)
+	}
+
+	// Surface
+	if anc.IsUpwardLooking() {
+		return depth, depth > 0
+	}
+
+	// Bottom
+	if r, ok := ens.GetBottomTrackData().MinRange(); ok {
+		return r, true
+	}
+	if filter.WaterDepth > depth {
+		return filter.WaterDepth - depth, true
+	}
+
+	return 0, false
+}
@@ -1,5 +1,10 @@
 package rti
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // SerialNumber is the ADCP serial number.
 // It includes the hardware type, Subsystem Configurations
 // Spare and the serial number.
@@ -17,8 +22,8 @@ type AdcpBaseHardware int
 // ADCP Base Hardware Enum value
 const (
 	AdcpHardware00 AdcpBaseHardware = 1 + iota // ADCP Base Hardware 00
-	AdcpHardware01                  = iota     // ADCP Base Hardware 01
-	AdcpHardware02                  = iota     // ADCP Base Hardware 02
+	AdcpHardware01                             // ADCP Base Hardware 01
+	AdcpHardware02                             // ADCP Base Hardware 02
 )
 
 // adcpBaseHardwares is the string representation of the Base Hardware enum
@@ -31,28 +36,65 @@ var adcpBaseHardwares = [...]string{
 // ToString is the ADCP Hardware to string
 func (h AdcpBaseHardware) ToString() string { return adcpBaseHardwares[h-1] }
 
-// Subsystem is the ADCP Subsystem Configuration
-type Subsystem byte
+// Serial number layout
+// The serial number is 32 characters.
+// [Base Hardware 2][Subsystems 15][Spare 9][Serial Number 6]
+const serialHardwareLen = 2   // Number of characters for the base hardware
+const serialSubsystemLen = 15 // Number of characters for the subsystems
+const serialSpareLen = 9      // Number of characters for the spare
+const serialNumLen = 6        // Number of characters for the serial number
+const serialLen = serialHardwareLen + serialSubsystemLen + serialSpareLen + serialNumLen
 
-const (
-	// SubEmpty -  Empty ASCI: 0 HEX: 0x0
-	SubEmpty Subsystem = 0x0
-	// SubSpare0 - Spare ASCII: 0  HEX: 30
-	SubSpare0 Subsystem = 0x30
-	// Sub2Mhz4Beam20DegPiston1 -  2MHz 4Beam 20 Degree Piston  ASCII: 1 HEX: 0x31
-	Sub2Mhz4Beam20DegPiston1 Subsystem = 0x31
-)
+// ParseSerialNumber will parse the serial number string into the base hardware,
+// subsystems, spare and serial number.  An error is returned if the string
+// does not match the RTI serial number layout.
+func ParseSerialNumber(serial string) (SerialNumber, error) {
+	sn := SerialNumber{SerialNumber: serial}
+
+	if len(serial) != serialLen {
+		return sn, fmt.Errorf("serial number %q must be %d characters", serial, serialLen)
+	}
+
+	// Base Hardware
+	ptr := 0
+	hardware, err := strconv.Atoi(serial[ptr : ptr+serialHardwareLen])
+	if err != nil || hardware < 0 || hardware >= len(adcpBaseHardwares) {
+		return sn, fmt.Errorf("serial number %q has invalid base hardware %q", serial, serial[ptr:ptr+serialHardwareLen])
+	}
+	sn.Hardware = AdcpBaseHardware(hardware + 1)
+	ptr += serialHardwareLen
+
+	// Subsystems
+	// Unused subsystem characters are the spare 0
+	for i := ptr; i < ptr+serialSubsystemLen; i++ {
+		ss := Subsystem(serial[i])
+		if !isSubsystemChar(serial[i]) {
+			return sn, fmt.Errorf("serial number %q has invalid subsystem %q", serial, serial[i])
+		}
+		if ss != SubSpare0 {
+			sn.Subsystems = append(sn.Subsystems, ss)
+		}
+	}
+	ptr += serialSubsystemLen
+
+	// Spare
+	sn.Spare = serial[ptr : ptr+serialSpareLen]
+	ptr += serialSpareLen
 
-// ToString is the ADCP Subystem to string
-func (ss Subsystem) ToString() string {
-	switch ss {
-	case SubEmpty:
-		return "Empty"
-	case SubSpare0:
-		return "Spare"
-	case Sub2Mhz4Beam20DegPiston1:
-		return "2MHz 4Beam 20 Degree Piston"
-	default:
-		return ""
+	// Serial Number
+	num := serial[ptr : ptr+serialNumLen]
+	for i := 0; i < len(num); i++ {
+		if num[i] < '0' || num[i] > '9' {
+			return sn, fmt.Errorf("serial number %q has invalid serial number %q", serial, num)
+		}
 	}
+	sn.SerialNum, _ = strconv.Atoi(num)
+
+	return sn, nil
+}
+
+// isSubsystemChar will check if the character can be a subsystem code.
+// Subsystem codes are ASCII digits and letters.
+func isSubsystemChar(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
 }
@@ -0,0 +1,84 @@
+package rti
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseSerialNumber will check the base hardware, subsystems, spare
+// and serial number of the 2+15+9+6 character layout.
+func TestParseSerialNumber(t *testing.T) {
+	tests := []struct {
+		serial     string
+		hardware   AdcpBaseHardware
+		subsystems []Subsystem
+		spare      string
+		num        int
+	}{
+		{"01300000000000000000000000000001", AdcpHardware01, []Subsystem{Sub600Khz4Beam20DegPiston3}, "000000000", 1},
+		{"0032A000000000000SPARE0001000123", AdcpHardware00, []Subsystem{Sub600Khz4Beam20DegPiston3, Sub1200Khz4Beam20DegPiston2, Sub1200KhzVertPistonA}, "SPARE0001", 123},
+		{"02000000000000000000000000999999", AdcpHardware02, nil, "000000000", 999999},
+	}
+
+	for _, test := range tests {
+		sn, err := ParseSerialNumber(test.serial)
+		if err != nil {
+			t.Errorf("ParseSerialNumber(%q): %v", test.serial, err)
+			continue
+		}
+
+		if sn.SerialNumber != test.serial {
+			t.Errorf("ParseSerialNumber(%q).SerialNumber = %q", test.serial, sn.SerialNumber)
+		}
+		if sn.Hardware != test.hardware {
+			t.Errorf("ParseSerialNumber(%q).Hardware = %s, want %s", test.serial, sn.Hardware.ToString(), test.hardware.ToString())
+		}
+		if !reflect.DeepEqual(sn.Subsystems, test.subsystems) {
+			t.Errorf("ParseSerialNumber(%q).Subsystems = %q, want %q", test.serial, sn.Subsystems, test.subsystems)
+		}
+		if sn.Spare != test.spare {
+			t.Errorf("ParseSerialNumber(%q).Spare = %q, want %q", test.serial, sn.Spare, test.spare)
+		}
+		if sn.SerialNum != test.num {
+			t.Errorf("ParseSerialNumber(%q).SerialNum = %d, want %d", test.serial, sn.SerialNum, test.num)
+		}
+	}
+}
+
+// TestParseSerialNumberInvalid will check the malformed serial numbers are rejected.
+func TestParseSerialNumberInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"0130000000000000000000000000001",   // Too short
+		"013000000000000000000000000000011", // Too long
+		"03300000000000000000000000000001",  // Unknown base hardware
+		"x1300000000000000000000000000001",  // Base hardware is not a number
+		"013-0000000000000000000000000001",  // Subsystem is not a digit or letter
+		"0130000000000000000000000000001a",  // Serial number is not a number
+	}
+
+	for _, serial := range tests {
+		if _, err := ParseSerialNumber(serial); err == nil {
+			t.Errorf("ParseSerialNumber(%q) did not return an error", serial)
+		}
+	}
+}
+
+// TestAdcpBaseHardwareString will check the hardware enum starts at 1 and
+// matches the names.
+func TestAdcpBaseHardwareString(t *testing.T) {
+	tests := []struct {
+		hardware AdcpBaseHardware
+		want     string
+	}{
+		{AdcpHardware00, "00"},
+		{AdcpHardware01, "01"},
+		{AdcpHardware02, "02"},
+	}
+
+	for _, test := range tests {
+		if got := test.hardware.ToString(); got != test.want {
+			t.Errorf("AdcpBaseHardware(%d).ToString() = %q, want %q", int(test.hardware), got, test.want)
+		}
+	}
+}
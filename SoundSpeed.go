@@ -0,0 +1,240 @@
+package rti
+
+import (
+	"errors"
+	"math"
+)
+
+// SoundSpeedFormula is the formula used to compute the speed of sound in sea water.
+type SoundSpeedFormula int
+
+// Speed of sound formulas
+const (
+	ChenMillero SoundSpeedFormula = iota // UNESCO Chen and Millero (1977) formula
+	Mackenzie                            // Mackenzie (1981) nine term formula
+	DelGrosso                            // Del Grosso (1974) formula
+)
+
+// String will get the name of the formula.
+func (formula SoundSpeedFormula) String() string {
+	switch formula {
+	case ChenMillero:
+		return "Chen-Millero"
+	case Mackenzie:
+		return "Mackenzie"
+	case DelGrosso:
+		return "Del Grosso"
+	default:
+		return "Unknown"
+	}
+}
+
+// SoundSpeed will compute the speed of sound in m/s with the formula.
+// The temperature is in degrees Celsius, the salinity is in PPT and
+// the depth is in meters.  The latitude in degrees is used to convert
+// the depth to pressure.
+func (formula SoundSpeedFormula) SoundSpeed(temperature float64, salinity float64, depth float64, latitude float64) float64 {
+	switch formula {
+	case Mackenzie:
+		return MackenzieSoundSpeed(temperature, salinity, depth)
+	case DelGrosso:
+		return DelGrossoSoundSpeed(temperature, salinity, DepthToPressure(depth, latitude))
+	default:
+		return ChenMilleroSoundSpeed(temperature, salinity, DepthToPressure(depth, latitude))
+	}
+}
+
+// soundSpeedPressure will compute the speed of sound in m/s with the formula.
+// The pressure is in decibars.  The latitude in degrees is used to convert
+// the pressure to depth for the Mackenzie formula.
+func (formula SoundSpeedFormula) soundSpeedPressure(temperature float64, salinity float64, pressure float64, latitude float64) float64 {
+	switch formula {
+	case Mackenzie:
+		return MackenzieSoundSpeed(temperature, salinity, PressureToDepth(pressure, latitude))
+	case DelGrosso:
+		return DelGrossoSoundSpeed(temperature, salinity, pressure)
+	default:
+		return ChenMilleroSoundSpeed(temperature, salinity, pressure)
+	}
+}
+
+// ChenMilleroSoundSpeed will compute the speed of sound in m/s with the UNESCO
+// Chen and Millero formula.  The temperature is in degrees Celsius, the salinity
+// is in PPT and the pressure is in decibars.
+// Valid for 0 to 40 C, 0 to 40 PPT and 0 to 10000 dBar.
+func ChenMilleroSoundSpeed(temperature float64, salinity float64, pressure float64) float64 {
+	t := temperature
+	s := salinity
+	p := pressure / 10.0 // Bar
+
+	cw := (1402.388 + 5.03711*t - 5.80852e-2*t*t + 3.3420e-4*t*t*t - 1.47800e-6*t*t*t*t + 3.1464e-9*t*t*t*t*t) +
+		(0.153563+6.8982e-4*t-8.1788e-6*t*t+1.3621e-7*t*t*t-6.1185e-10*t*t*t*t)*p +
+		(3.1260e-5-1.7107e-6*t+2.5974e-8*t*t-2.5335e-10*t*t*t+1.0405e-12*t*t*t*t)*p*p +
+		(-9.7729e-9+3.8504e-10*t-2.3643e-12*t*t)*p*p*p
+
+	a := (1.389 - 1.262e-2*t + 7.164e-5*t*t + 2.006e-6*t*t*t - 3.21e-8*t*t*t*t) +
+		(9.4742e-5-1.2580e-5*t-6.4885e-8*t*t+1.0507e-8*t*t*t-2.0122e-10*t*t*t*t)*p +
+		(-3.9064e-7+9.1041e-9*t-1.6002e-10*t*t+7.988e-12*t*t*t)*p*p +
+		(1.100e-10+6.649e-12*t-3.389e-13*t*t)*p*p*p
+
+	b := -1.922e-2 - 4.42e-5*t + (7.3637e-5+1.7945e-7*t)*p
+
+	d := 1.727e-3 - 7.9836e-6*p
+
+	return cw + a*s + b*math.Pow(s, 1.5) + d*s*s
+}
+
+// MackenzieSoundSpeed will compute the speed of sound in m/s with the Mackenzie
+// formula.  The temperature is in degrees Celsius, the salinity is in PPT and
+// the depth is in meters.
+// Valid for 2 to 30 C, 25 to 40 PPT and 0 to 8000 m.
+func MackenzieSoundSpeed(temperature float64, salinity float64, depth float64) float64 {
+	t := temperature
+	s := salinity - 35.0
+	d := depth
+
+	return 1448.96 + 4.591*t - 5.304e-2*t*t + 2.374e-4*t*t*t +
+		1.340*s + 1.630e-2*d + 1.675e-7*d*d -
+		1.025e-2*t*s - 7.139e-13*t*d*d*d
+}
+
+// DelGrossoSoundSpeed will compute the speed of sound in m/s with the Del Grosso
+// formula.  The temperature is in degrees Celsius, the salinity is in PPT and
+// the pressure is in decibars.
+// Valid for 0 to 30 C, 30 to 40 PPT and 0 to 10000 dBar.
+func DelGrossoSoundSpeed(temperature float64, salinity float64, pressure float64) float64 {
+	t := temperature
+	s := salinity
+	p := pressure * 0.1019716 // kg/cm^2
+
+	ct := 5.012285*t - 5.51184e-2*t*t + 2.21649e-4*t*t*t
+	cs := 1.329530*s + 1.288598e-4*s*s
+	cp := 0.1560592*p + 2.449993e-5*p*p - 8.833959e-9*p*p*p
+	cstp := -1.275936e-2*t*s + 6.353509e-3*t*p + 2.656174e-8*t*t*p*p -
+		1.593895e-6*t*p*p + 5.222483e-10*t*p*p*p - 4.383615e-7*t*t*t*p -
+		1.616745e-9*s*s*p*p + 9.688441e-5*s*t*t + 4.857614e-6*s*s*t*p -
+		3.406824e-4*t*s*p
+
+	return 1402.392 + ct + cs + cp + cstp
+}
+
+// DepthToPressure will convert the depth in meters to pressure in decibars
+// using the Saunders (1981) formula.  The latitude is in degrees.
+func DepthToPressure(depth float64, latitude float64) float64 {
+	sinLat := math.Sin(latitude * (math.Pi / 180.0))
+	c1 := (5.92 + 5.25*sinLat*sinLat) * 1e-3
+
+	return ((1 - c1) - math.Sqrt((1-c1)*(1-c1)-8.84e-6*depth)) / 4.42e-6
+}
+
+// CorrectSoundSpeed will recompute the speed of sound with the formula using
+// the salinity given and the water temperature and pressure measured by the ADCP.
+// If there is no pressure, the transducer depth is used.  The velocities and bin
+// sizes are rescaled to the new speed of sound and the Ancillary Data Set speed
+// of sound and salinity are updated.  This is used when the ADCP was configured
+// with the wrong salinity.  The depth options give the latitude and atmospheric pressure.
+// The new speed of sound in m/s is returned.
+func (ens *Ensemble) CorrectSoundSpeed(formula SoundSpeedFormula, salinity float64, opts DepthOptions) (float32, error) {
+	anc := ens.GetAncillaryData()
+
+	pressure, err := ens.SeaPressure(opts)
+	if err != nil {
+		pressure = DepthToPressure(float64(anc.TransducerDepth), opts.Latitude)
+	}
+
+	speedOfSound := float32(formula.soundSpeedPressure(float64(anc.WaterTemp), salinity, pressure, opts.Latitude))
+	if err := ens.RescaleSoundSpeed(speedOfSound); err != nil {
+		return 0, err
+	}
+
+	anc.Salinity = float32(salinity)
+	ens.GetBottomTrackData().Salinity = float32(salinity)
+
+	return speedOfSound, nil
+}
+
+// RescaleSoundSpeed will rescale the velocities, bin sizes and bottom track
+// ranges from the speed of sound used by the ADCP to the new speed of sound in m/s.
+// This is used when the ADCP was configured with a fixed speed of sound that was wrong.
+// The Ancillary Data Set speed of sound is updated.
+//
+// The velocities and ranges are proportional to the speed of sound.  The horizontal
+// velocities of a phased array ADCP do not depend on the speed of sound, so only
+// the vertical velocities are rescaled and the beam velocities are not changed.
+func (ens *Ensemble) RescaleSoundSpeed(speedOfSound float32) error {
+	anc := ens.GetAncillaryData()
+	if anc.SpeedOfSound <= 0 || IsBad(anc.SpeedOfSound) {
+		return errors.New("speed of sound used by the ADCP is unknown")
+	}
+	if speedOfSound <= 0 {
+		return errors.New("invalid speed of sound")
+	}
+
+	scale := speedOfSound / anc.SpeedOfSound
+	phasedArray := Subsystem(ens.GetEnsembleData().Firmware.SubsystemCode).IsPhasedArray()
+
+	// Velocities
+	if !phasedArray {
+		scaleValues(ens.GetBeamVelocityData().Velocity.Data, scale)
+	}
+	scaleVelocities(ens.GetInstrumentVelocityData().Velocity, InstrumentZ, scale, phasedArray)
+	earth := ens.GetEarthVelocityData()
+	scaleVelocities(earth.Velocity, EarthVertical, scale, phasedArray)
+	earth.calcVectors()
+
+	// Bin sizes
+	anc.FirstBinRange *= scale
+	anc.BinSize *= scale
+	anc.SpeedOfSound = speedOfSound
+
+	// Bottom Track
+	bt := ens.GetBottomTrackData()
+	scaleValues(bt.Range, scale)
+	if !phasedArray {
+		scaleValues(bt.BeamVelocity, scale)
+	}
+	scaleComponents(bt.InstrumentVelocity, InstrumentZ, scale, phasedArray)
+	scaleComponents(bt.EarthVelocity, EarthVertical, scale, phasedArray)
+	if bt.SpeedOfSound > 0 {
+		bt.SpeedOfSound = speedOfSound
+	}
+
+	// Vertical beam
+	if vb := ens.VerticalBeamData; vb != nil {
+		scaleValues(vb.Velocity, scale)
+		vb.FirstBinRange *= scale
+		vb.BinSize *= scale
+	}
+
+	return nil
+}
+
+// scaleValues will scale all the good values.
+func scaleValues(values []float32, scale float32) {
+	for i, v := range values {
+		if !IsBad(v) {
+			values[i] = v * scale
+		}
+	}
+}
+
+// scaleVelocities will scale the velocities in each bin.
+// If only vertical is set, only the vertical component is scaled.
+func scaleVelocities(vel BinBeamMatrix[float32], vertical int, scale float32, onlyVertical bool) {
+	for bin := 0; bin < vel.NumBins; bin++ {
+		scaleComponents(vel.Bin(bin), vertical, scale, onlyVertical)
+	}
+}
+
+// scaleComponents will scale the velocity components.
+// If only vertical is set, only the vertical component is scaled.
+func scaleComponents(vel []float32, vertical int, scale float32, onlyVertical bool) {
+	if !onlyVertical {
+		scaleValues(vel, scale)
+		return
+	}
+
+	if vertical < len(vel) && !IsBad(vel[vertical]) {
+		vel[vertical] *= scale
+	}
+}
@@ -0,0 +1,44 @@
+package rti
+
+import (
+	"math"
+	"testing"
+)
+
+// TestChenMilleroSoundSpeed will check the UNESCO (Fofonoff and Millard 1983) check value.
+func TestChenMilleroSoundSpeed(t *testing.T) {
+	if c := ChenMilleroSoundSpeed(40, 40, 10000); math.Abs(c-1731.995) > 0.001 {
+		t.Errorf("ChenMilleroSoundSpeed(40, 40, 10000) = %.3f, want 1731.995", c)
+	}
+}
+
+// TestMackenzieSoundSpeed will check the Mackenzie (1981) check value.
+func TestMackenzieSoundSpeed(t *testing.T) {
+	if c := MackenzieSoundSpeed(25, 35, 1000); math.Abs(c-1550.744) > 0.001 {
+		t.Errorf("MackenzieSoundSpeed(25, 35, 1000) = %.3f, want 1550.744", c)
+	}
+}
+
+// TestDelGrossoSoundSpeed will check the Del Grosso formula against the UNESCO
+// formula.  The formulas agree within a few tenths of a m/s in sea water.
+func TestDelGrossoSoundSpeed(t *testing.T) {
+	tests := []struct {
+		temperature, salinity, pressure float64
+		tolerance                       float64
+	}{
+		{0, 35, 0, 0.1},
+		{10, 35, 0, 0.1},
+		{25, 35, 0, 0.1},
+		{20, 30, 0, 0.1},
+		{10, 35, 1000, 0.3},
+		{2, 34.7, 5000, 0.7},
+	}
+
+	for _, test := range tests {
+		dg := DelGrossoSoundSpeed(test.temperature, test.salinity, test.pressure)
+		cm := ChenMilleroSoundSpeed(test.temperature, test.salinity, test.pressure)
+		if math.Abs(dg-cm) > test.tolerance {
+			t.Errorf("DelGrossoSoundSpeed(%g, %g, %g) = %.3f, Chen-Millero %.3f", test.temperature, test.salinity, test.pressure, dg, cm)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package rti
+
+import "strings"
+
+// Status is the ADCP status.
+// Each bit in the status is a condition found by the ADCP
+// while pinging the ensemble.
+type Status uint32
+
+// ADCP Status flags
+const (
+	StatusGood                 Status = 0x0000 // No conditions found
+	StatusWaterTrack3Beam      Status = 0x0001 // Water track used a 3 beam solution
+	StatusBottomTrack3Beam     Status = 0x0002 // Bottom track used a 3 beam solution
+	StatusBottomTrackHold      Status = 0x0004 // Bottom track is holding the last good value
+	StatusBottomTrackSearching Status = 0x0008 // Bottom track is searching for the bottom
+	StatusHeadingSensorError   Status = 0x0100 // Heading sensor error (bad compass)
+	StatusPressureSensorError  Status = 0x0200 // Pressure sensor error
+	StatusPowerDownFailure     Status = 0x0400 // Power down failure
+	StatusNonVolatileDataError Status = 0x0800 // Non-volatile data error
+	StatusRealTimeClockError   Status = 0x1000 // Real time clock error
+	StatusTemperatureError     Status = 0x2000 // Temperature sensor error
+	StatusReceiverDataError    Status = 0x4000 // Receiver data error
+	StatusReceiverTimeout      Status = 0x8000 // Receiver timeout
+	StatusHardwareTimeout      Status = 0xFFFF // Hardware timeout, all the status bits are set
+)
+
+// statusFlags is all the single bit status flags and their names.
+var statusFlags = []struct {
+	flag Status
+	name string
+}{
+	{StatusWaterTrack3Beam, "Water Track 3 Beam Solution"},
+	{StatusBottomTrack3Beam, "Bottom Track 3 Beam Solution"},
+	{StatusBottomTrackHold, "Bottom Track Hold"},
+	{StatusBottomTrackSearching, "Bottom Track Searching"},
+	{StatusHeadingSensorError, "Heading Sensor Error"},
+	{StatusPressureSensorError, "Pressure Sensor Error"},
+	{StatusPowerDownFailure, "Power Down Failure"},
+	{StatusNonVolatileDataError, "Non-Volatile Data Error"},
+	{StatusRealTimeClockError, "Real Time Clock Error"},
+	{StatusTemperatureError, "Temperature Error"},
+	{StatusReceiverDataError, "Receiver Data Error"},
+	{StatusReceiverTimeout, "Receiver Timeout"},
+}
+
+// Has will check if the status has the flag set.
+// StatusGood is only true when no flags are set.
+func (status Status) Has(flag Status) bool {
+	if flag == StatusGood {
+		return status == StatusGood
+	}
+
+	return status&flag == flag
+}
+
+// IsHardwareTimeout will check if the hardware timed out.
+// A hardware timeout sets all the status bits.
+func (status Status) IsHardwareTimeout() bool {
+	return status.Has(StatusHardwareTimeout)
+}
+
+// Flags will get all the flags that are set.  A hardware timeout
+// is given as a single flag instead of all the status bits.
+func (status Status) Flags() []Status {
+	if status.IsHardwareTimeout() {
+		return []Status{StatusHardwareTimeout}
+	}
+
+	var flags []Status
+	for _, sf := range statusFlags {
+		if status.Has(sf.flag) {
+			flags = append(flags, sf.flag)
+		}
+	}
+
+	return flags
+}
+
+// String will list all the conditions in the status.
+func (status Status) String() string {
+	if status == StatusGood {
+		return "Good"
+	}
+
+	if status.IsHardwareTimeout() {
+		return "Hardware Timeout"
+	}
+
+	var names []string
+	for _, sf := range statusFlags {
+		if status.Has(sf.flag) {
+			names = append(names, sf.name)
+		}
+	}
+
+	if len(names) == 0 {
+		return "Unknown"
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -1,5 +1,161 @@
 package rti
 
+// Subsystem is the ADCP Subsystem Configuration
+//
+// The codes are the RTI subsystem code list, 1 to O.  That list has no 2.4MHz
+// and no 3 beam subsystem codes, so they are not in the table.  A 4 beam
+// subsystem can run with 3 beams; the Ensemble Data Set NumBeams gives the
+// number of beams actually sent.  Codes RTI adds later are not known until
+// they are added to the table, and IsKnown returns false for them.
+type Subsystem byte
+
+const (
+	// SubEmpty -  Empty ASCI: 0 HEX: 0x0
+	SubEmpty Subsystem = 0x0
+	// SubSpare0 - Spare ASCII: 0  HEX: 30
+	SubSpare0 Subsystem = 0x30
+	// Sub2Mhz4Beam20DegPiston1 - 2MHz 4Beam 20 Degree Piston ASCII: 1 HEX: 0x31
+	Sub2Mhz4Beam20DegPiston1 Subsystem = 0x31
+	// Sub1200Khz4Beam20DegPiston2 - 1.2MHz 4Beam 20 Degree Piston ASCII: 2 HEX: 0x32
+	Sub1200Khz4Beam20DegPiston2 Subsystem = 0x32
+	// Sub600Khz4Beam20DegPiston3 - 600kHz 4Beam 20 Degree Piston ASCII: 3 HEX: 0x33
+	Sub600Khz4Beam20DegPiston3 Subsystem = 0x33
+	// Sub300Khz4Beam20DegPiston4 - 300kHz 4Beam 20 Degree Piston ASCII: 4 HEX: 0x34
+	Sub300Khz4Beam20DegPiston4 Subsystem = 0x34
+	// Sub2Mhz4Beam20DegPiston45Offset5 - 2MHz 4Beam 20 Degree Piston 45 Degree Offset ASCII: 5 HEX: 0x35
+	Sub2Mhz4Beam20DegPiston45Offset5 Subsystem = 0x35
+	// Sub1200Khz4Beam20DegPiston45Offset6 - 1.2MHz 4Beam 20 Degree Piston 45 Degree Offset ASCII: 6 HEX: 0x36
+	Sub1200Khz4Beam20DegPiston45Offset6 Subsystem = 0x36
+	// Sub600Khz4Beam20DegPiston45Offset7 - 600kHz 4Beam 20 Degree Piston 45 Degree Offset ASCII: 7 HEX: 0x37
+	Sub600Khz4Beam20DegPiston45Offset7 Subsystem = 0x37
+	// Sub300Khz4Beam20DegPiston45Offset8 - 300kHz 4Beam 20 Degree Piston 45 Degree Offset ASCII: 8 HEX: 0x38
+	Sub300Khz4Beam20DegPiston45Offset8 Subsystem = 0x38
+	// Sub2MhzVertPiston9 - 2MHz Vertical Piston ASCII: 9 HEX: 0x39
+	Sub2MhzVertPiston9 Subsystem = 0x39
+	// Sub1200KhzVertPistonA - 1.2MHz Vertical Piston ASCII: A HEX: 0x41
+	Sub1200KhzVertPistonA Subsystem = 0x41
+	// Sub600KhzVertPistonB - 600kHz Vertical Piston ASCII: B HEX: 0x42
+	Sub600KhzVertPistonB Subsystem = 0x42
+	// Sub300KhzVertPistonC - 300kHz Vertical Piston ASCII: C HEX: 0x43
+	Sub300KhzVertPistonC Subsystem = 0x43
+	// Sub150Khz4Beam30DegArrayD - 150kHz 4Beam 30 Degree Phased Array ASCII: D HEX: 0x44
+	Sub150Khz4Beam30DegArrayD Subsystem = 0x44
+	// Sub75Khz4Beam30DegArrayE - 75kHz 4Beam 30 Degree Phased Array ASCII: E HEX: 0x45
+	Sub75Khz4Beam30DegArrayE Subsystem = 0x45
+	// Sub38Khz4Beam30DegArrayF - 38kHz 4Beam 30 Degree Phased Array ASCII: F HEX: 0x46
+	Sub38Khz4Beam30DegArrayF Subsystem = 0x46
+	// Sub20Khz4Beam30DegArrayG - 20kHz 4Beam 30 Degree Phased Array ASCII: G HEX: 0x47
+	Sub20Khz4Beam30DegArrayG Subsystem = 0x47
+	// Sub150Khz4Beam15DegArrayH - 150kHz 4Beam 15 Degree Phased Array ASCII: H HEX: 0x48
+	Sub150Khz4Beam15DegArrayH Subsystem = 0x48
+	// Sub75Khz4Beam15DegArrayI - 75kHz 4Beam 15 Degree Phased Array ASCII: I HEX: 0x49
+	Sub75Khz4Beam15DegArrayI Subsystem = 0x49
+	// Sub38Khz4Beam15DegArrayJ - 38kHz 4Beam 15 Degree Phased Array ASCII: J HEX: 0x4A
+	Sub38Khz4Beam15DegArrayJ Subsystem = 0x4A
+	// Sub20Khz4Beam15DegArrayK - 20kHz 4Beam 15 Degree Phased Array ASCII: K HEX: 0x4B
+	Sub20Khz4Beam15DegArrayK Subsystem = 0x4B
+	// Sub150Khz1BeamArrayL - 150kHz 1Beam Phased Array ASCII: L HEX: 0x4C
+	Sub150Khz1BeamArrayL Subsystem = 0x4C
+	// Sub75Khz1BeamArrayM - 75kHz 1Beam Phased Array ASCII: M HEX: 0x4D
+	Sub75Khz1BeamArrayM Subsystem = 0x4D
+	// Sub38Khz1BeamArrayN - 38kHz 1Beam Phased Array ASCII: N HEX: 0x4E
+	Sub38Khz1BeamArrayN Subsystem = 0x4E
+	// Sub20Khz1BeamArrayO - 20kHz 1Beam Phased Array ASCII: O HEX: 0x4F
+	Sub20Khz1BeamArrayO Subsystem = 0x4F
+)
+
+// subsystemInfo describes the transducer of a subsystem.
+type subsystemInfo struct {
+	desc        string  // Description of the subsystem
+	frequency   float64 // Frequency in Hz
+	numBeams    int     // Number of beams
+	beamAngle   float64 // Beam angle from vertical in degrees
+	beamOffset  float64 // Rotation of the beams about the instrument Z axis in degrees
+	phasedArray bool    // Phased array transducer, otherwise piston
+}
+
+// subsystems is the RTI subsystem code table.  Codes that are not in the table
+// are not known and have no beam geometry.
+var subsystems = map[Subsystem]subsystemInfo{
+	Sub2Mhz4Beam20DegPiston1:            {"2MHz 4Beam 20 Degree Piston", 2000000, 4, 20, 0, false},
+	Sub1200Khz4Beam20DegPiston2:         {"1.2MHz 4Beam 20 Degree Piston", 1200000, 4, 20, 0, false},
+	Sub600Khz4Beam20DegPiston3:          {"600kHz 4Beam 20 Degree Piston", 600000, 4, 20, 0, false},
+	Sub300Khz4Beam20DegPiston4:          {"300kHz 4Beam 20 Degree Piston", 300000, 4, 20, 0, false},
+	Sub2Mhz4Beam20DegPiston45Offset5:    {"2MHz 4Beam 20 Degree Piston 45 Degree Offset", 2000000, 4, 20, 45, false},
+	Sub1200Khz4Beam20DegPiston45Offset6: {"1.2MHz 4Beam 20 Degree Piston 45 Degree Offset", 1200000, 4, 20, 45, false},
+	Sub600Khz4Beam20DegPiston45Offset7:  {"600kHz 4Beam 20 Degree Piston 45 Degree Offset", 600000, 4, 20, 45, false},
+	Sub300Khz4Beam20DegPiston45Offset8:  {"300kHz 4Beam 20 Degree Piston 45 Degree Offset", 300000, 4, 20, 45, false},
+	Sub2MhzVertPiston9:                  {"2MHz Vertical Piston", 2000000, 1, 0, 0, false},
+	Sub1200KhzVertPistonA:               {"1.2MHz Vertical Piston", 1200000, 1, 0, 0, false},
+	Sub600KhzVertPistonB:                {"600kHz Vertical Piston", 600000, 1, 0, 0, false},
+	Sub300KhzVertPistonC:                {"300kHz Vertical Piston", 300000, 1, 0, 0, false},
+	Sub150Khz4Beam30DegArrayD:           {"150kHz 4Beam 30 Degree Phased Array", 150000, 4, 30, 0, true},
+	Sub75Khz4Beam30DegArrayE:            {"75kHz 4Beam 30 Degree Phased Array", 75000, 4, 30, 0, true},
+	Sub38Khz4Beam30DegArrayF:            {"38kHz 4Beam 30 Degree Phased Array", 38000, 4, 30, 0, true},
+	Sub20Khz4Beam30DegArrayG:            {"20kHz 4Beam 30 Degree Phased Array", 20000, 4, 30, 0, true},
+	Sub150Khz4Beam15DegArrayH:           {"150kHz 4Beam 15 Degree Phased Array", 150000, 4, 15, 0, true},
+	Sub75Khz4Beam15DegArrayI:            {"75kHz 4Beam 15 Degree Phased Array", 75000, 4, 15, 0, true},
+	Sub38Khz4Beam15DegArrayJ:            {"38kHz 4Beam 15 Degree Phased Array", 38000, 4, 15, 0, true},
+	Sub20Khz4Beam15DegArrayK:            {"20kHz 4Beam 15 Degree Phased Array", 20000, 4, 15, 0, true},
+	Sub150Khz1BeamArrayL:                {"150kHz 1Beam Phased Array", 150000, 1, 0, 0, true},
+	Sub75Khz1BeamArrayM:                 {"75kHz 1Beam Phased Array", 75000, 1, 0, 0, true},
+	Sub38Khz1BeamArrayN:                 {"38kHz 1Beam Phased Array", 38000, 1, 0, 0, true},
+	Sub20Khz1BeamArrayO:                 {"20kHz 1Beam Phased Array", 20000, 1, 0, 0, true},
+}
+
+// ToString is the ADCP Subystem to string
+func (ss Subsystem) ToString() string {
+	switch ss {
+	case SubEmpty:
+		return "Empty"
+	case SubSpare0:
+		return "Spare"
+	default:
+		return subsystems[ss].desc
+	}
+}
+
+// IsKnown will check if the subsystem code is in the subsystem table.
+func (ss Subsystem) IsKnown() bool {
+	_, ok := subsystems[ss]
+	return ok
+}
+
+// Frequency will get the transducer frequency in Hz.
+// If the subsystem is not known, 0 is returned.
+func (ss Subsystem) Frequency() float64 {
+	return subsystems[ss].frequency
+}
+
+// BeamAngle will get the beam angle from vertical in degrees.
+// A vertical beam has a beam angle of 0.
+func (ss Subsystem) BeamAngle() float64 {
+	return subsystems[ss].beamAngle
+}
+
+// BeamOffset will get the rotation of the beams about the instrument
+// Z axis in degrees.  The 45 degree offset subsystems return 45.
+func (ss Subsystem) BeamOffset() float64 {
+	return subsystems[ss].beamOffset
+}
+
+// NumBeams will get the number of beams in the subsystem.
+// If the subsystem is not known, 0 is returned.
+func (ss Subsystem) NumBeams() int {
+	return subsystems[ss].numBeams
+}
+
+// IsVertical will check if the subsystem is a single vertical beam.
+func (ss Subsystem) IsVertical() bool {
+	return subsystems[ss].numBeams == 1
+}
+
+// IsPhasedArray will check if the subsystem is a phased array transducer.
+// Otherwise the subsystem uses piston transducers.
+func (ss Subsystem) IsPhasedArray() bool {
+	return subsystems[ss].phasedArray
+}
+
 // SubsystemConfiguration is the ADCP subsystem configuration.
 // An  ADCP can contain multiple frequencies and configurations.  A subsystem is the
 // frequency type.  The configuration is the configuration of that
@@ -0,0 +1,331 @@
+package rti
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Index of each velocity in the Instrument Velocity Data Set.
+const (
+	InstrumentX     = 0 // X velocity
+	InstrumentY     = 1 // Y velocity
+	InstrumentZ     = 2 // Z velocity
+	InstrumentError = 3 // Error velocity
+)
+
+// Index of each velocity in the Earth Velocity Data Set.
+const (
+	EarthEast     = 0 // East velocity
+	EarthNorth    = 1 // North velocity
+	EarthVertical = 2 // Vertical velocity
+	EarthError    = 3 // Error velocity
+)
+
+// numVelocityComponents is the number of velocities in the
+// Instrument and Earth Velocity Data Sets.
+const numVelocityComponents = 4
+
+// TransformOptions will set how the beam velocities are transformed.
+type TransformOptions struct {
+	BeamAngle  float64 // Beam angle in degrees.  If 0, the beam angle of the subsystem is used.
+	ThreeBeam  bool    // Use a 3 beam solution when one beam is bad in a 4 beam system
+	BinMapping bool    // Map the beam samples to the level bin depths using the pitch and roll
+}
+
+// Retransform will recompute the Instrument and Earth Velocity Data Sets from
+// the Beam Velocity Data Set.  The heading, pitch and roll are taken from the
+// Ancillary Data Set, so a new heading source can be used by setting the
+// heading in the Ancillary Data Set before calling Retransform.
+func (ens *Ensemble) Retransform(opts TransformOptions) error {
+	beamVel := ens.GetBeamVelocityData()
+	anc := ens.GetAncillaryData()
+	ss := Subsystem(ens.GetEnsembleData().Firmware.SubsystemCode)
+
+	// Beam angle
+	beamAngle := opts.BeamAngle
+	if beamAngle == 0 {
+		beamAngle = ss.BeamAngle()
+	}
+
+	matrix, err := BeamToInstrumentMatrix(beamAngle, beamVel.Velocity.NumBeams, ss.BeamOffset())
+	if err != nil {
+		return err
+	}
+
+	// Keep the declination and heading offset applied to the earth velocities
+	earth := ens.GetEarthVelocityData()
+	declination := earth.Declination
+	fromModel := earth.DeclinationFromModel
+	headingOffset := earth.HeadingOffset
+
+	// Map the tilted beam samples to the level bins
+	if opts.BinMapping {
+		mapped, err := MapBins(beamVel, anc, beamAngle, ss.BeamOffset())
+		if err != nil {
+			return err
+		}
+		beamVel = &mapped
+	}
+
+	ens.InstrumentVelocityData = BeamToInstrument(beamVel, matrix, opts.ThreeBeam)
+	ens.EarthVelocityData = InstrumentToEarth(&ens.InstrumentVelocityData, float64(anc.Heading), float64(anc.Pitch), float64(anc.Roll))
+	ens.EarthVelocityData.Rotate(declination, headingOffset)
+	ens.EarthVelocityData.DeclinationFromModel = fromModel
+
+	return nil
+}
+
+// BeamToInstrumentMatrix will create the matrix to transform beam velocities
+// to instrument velocities.  The matrix has a row for the X, Y, Z and error
+// velocity and a column for each beam.  The beam angle is from vertical in
+// degrees.  The beam offset is the rotation of the beams about the Z axis in degrees.
+//
+// 4 Beam
+// Beam 0 and 1 are on the X axis and beam 2 and 3 are on the Y axis.
+// X = (B0 - B1) / (2 sin(angle))
+// Y = (B3 - B2) / (2 sin(angle))
+// Z = (B0 + B1 + B2 + B3) / (4 cos(angle))
+// Err = (B0 + B1 - B2 - B3) / (2 sqrt(2) sin(angle))
+//
+// 3 Beam
+// Beam 0 is on the X axis and the beams are 120 degrees apart.
+// The error velocity can not be calculated and is 0.
+func BeamToInstrumentMatrix(beamAngle float64, numBeams int, beamOffset float64) ([][]float64, error) {
+	if beamAngle <= 0 || beamAngle >= 90 {
+		return nil, fmt.Errorf("invalid beam angle %g", beamAngle)
+	}
+
+	angle := beamAngle * (math.Pi / 180.0)
+	var matrix [][]float64
+
+	switch numBeams {
+	case 4:
+		a := 1.0 / (2.0 * math.Sin(angle))
+		b := 1.0 / (4.0 * math.Cos(angle))
+		d := a / math.Sqrt2
+		matrix = [][]float64{
+			{a, -a, 0, 0},
+			{0, 0, -a, a},
+			{b, b, b, b},
+			{d, d, -d, -d},
+		}
+	case 3:
+		// Beam direction
+		// B = X sin(angle) cos(azimuth) + Y sin(angle) sin(azimuth) + Z cos(angle)
+		var dir [3][3]float64
+		for beam := 0; beam < 3; beam++ {
+			azimuth := float64(beam) * (2.0 * math.Pi / 3.0)
+			dir[beam] = [3]float64{math.Sin(angle) * math.Cos(azimuth), math.Sin(angle) * math.Sin(azimuth), math.Cos(angle)}
+		}
+
+		inv, err := invert3(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		matrix = [][]float64{
+			inv[0][:],
+			inv[1][:],
+			inv[2][:],
+			{0, 0, 0},
+		}
+	default:
+		return nil, fmt.Errorf("can not transform %d beams", numBeams)
+	}
+
+	// Rotate the X and Y rows by the beam offset
+	if beamOffset != 0 {
+		offset := beamOffset * (math.Pi / 180.0)
+		cos, sin := math.Cos(offset), math.Sin(offset)
+		for beam := range matrix[0] {
+			x, y := matrix[0][beam], matrix[1][beam]
+			matrix[0][beam] = x*cos - y*sin
+			matrix[1][beam] = x*sin + y*cos
+		}
+	}
+
+	return matrix, nil
+}
+
+// BeamToInstrument will transform the beam velocities to instrument velocities
+// using the matrix from BeamToInstrumentMatrix.  If a beam is bad, all the
+// velocities in the bin are bad.  If threeBeam is set and only one beam in a
+// 4 beam system is bad, the bad beam is computed from the other 3 beams
+// assuming the error velocity is 0.
+func BeamToInstrument(beam *BeamVelocityDataSet, matrix [][]float64, threeBeam bool) InstrumentVelocityDataSet {
+	var inst InstrumentVelocityDataSet
+	inst.Base = newVelocityBase(beam.Base, instrumentVelocityID)
+	inst.Velocity = NewBinBeamMatrix[float32](beam.Velocity.NumBins, numVelocityComponents)
+	bad := inst.Base.badValue()
+
+	numBeams := beam.Velocity.NumBeams
+	beams := make([]float64, numBeams)
+
+	for bin := 0; bin < beam.Velocity.NumBins; bin++ {
+		// Find the bad beams
+		badBeam := -1
+		numBad := 0
+		for b, v := range beam.Velocity.Bin(bin) {
+			beams[b] = float64(v)
+			if IsBad(v) {
+				badBeam = b
+				numBad++
+			}
+		}
+
+		// 3 Beam solution
+		if numBad == 1 && threeBeam && numBeams == 4 {
+			beams[badBeam] = threeBeamSolution(beams, badBeam)
+			numBad = 0
+		}
+
+		// Bad bin
+		if numBad > 0 || len(matrix[0]) != numBeams {
+			for i := 0; i < numVelocityComponents; i++ {
+				inst.Velocity.Set(bin, i, bad)
+			}
+			continue
+		}
+
+		for i := 0; i < numVelocityComponents; i++ {
+			var sum float64
+			for b := 0; b < numBeams; b++ {
+				sum += matrix[i][b] * beams[b]
+			}
+			inst.Velocity.Set(bin, i, float32(sum))
+		}
+	}
+
+	return inst
+}
+
+// threeBeamSolution will compute the bad beam from the other 3 beams.
+// The error velocity is assumed to be 0, so B0 + B1 = B2 + B3.
+func threeBeamSolution(beams []float64, badBeam int) float64 {
+	switch badBeam {
+	case 0:
+		return beams[2] + beams[3] - beams[1]
+	case 1:
+		return beams[2] + beams[3] - beams[0]
+	case 2:
+		return beams[0] + beams[1] - beams[3]
+	default:
+		return beams[0] + beams[1] - beams[2]
+	}
+}
+
+// InstrumentToEarth will transform the instrument velocities to earth velocities
+// using the heading, pitch and roll in degrees.
+//
+// RTI Pitch and Roll convention
+// The roll of an upward looking ADCP is near 180 degrees.  The tilt sensor
+// pitch is corrected for the roll before the rotation.
+// Pitch = atan(tan(Pitch) * cos(Roll))
+// where Roll is within +/- 90 degrees.
+func InstrumentToEarth(inst *InstrumentVelocityDataSet, heading float64, pitch float64, roll float64) EarthVelocityDataSet {
+	var earth EarthVelocityDataSet
+	earth.Base = newVelocityBase(inst.Base, earthVelocityID)
+	earth.Velocity = NewBinBeamMatrix[float32](inst.Velocity.NumBins, numVelocityComponents)
+	bad := earth.Base.badValue()
+
+	rot := earthRotation(heading, pitch, roll)
+
+	for bin := 0; bin < inst.Velocity.NumBins; bin++ {
+		vel := inst.Velocity.Bin(bin)
+
+		// All the X, Y and Z velocities must be good
+		if len(vel) < 3 || IsBad(vel[InstrumentX]) || IsBad(vel[InstrumentY]) || IsBad(vel[InstrumentZ]) {
+			for i := 0; i < numVelocityComponents; i++ {
+				earth.Velocity.Set(bin, i, bad)
+			}
+			continue
+		}
+
+		for i := 0; i < 3; i++ {
+			sum := rot[i][0]*float64(vel[InstrumentX]) + rot[i][1]*float64(vel[InstrumentY]) + rot[i][2]*float64(vel[InstrumentZ])
+			earth.Velocity.Set(bin, i, float32(sum))
+		}
+
+		// Error velocity is not rotated
+		if len(vel) > InstrumentError {
+			earth.Velocity.Set(bin, EarthError, vel[InstrumentError])
+		} else {
+			earth.Velocity.Set(bin, EarthError, bad)
+		}
+	}
+
+	earth.calcVectors()
+
+	return earth
+}
+
+// earthRotation will create the rotation matrix from instrument to earth
+// coordinates for the heading, pitch and roll in degrees.
+func earthRotation(heading float64, pitch float64, roll float64) [3][3]float64 {
+	// Roll within +/- 90 to correct the pitch
+	tiltRoll := roll
+	if tiltRoll > 90 {
+		tiltRoll -= 180
+	} else if tiltRoll < -90 {
+		tiltRoll += 180
+	}
+
+	h := heading * (math.Pi / 180.0)
+	r := roll * (math.Pi / 180.0)
+	p := math.Atan(math.Tan(pitch*(math.Pi/180.0)) * math.Cos(tiltRoll*(math.Pi/180.0)))
+
+	sh, ch := math.Sin(h), math.Cos(h)
+	sp, cp := math.Sin(p), math.Cos(p)
+	sr, cr := math.Sin(r), math.Cos(r)
+
+	return [3][3]float64{
+		{ch*cr + sh*sp*sr, sh * cp, ch*sr - sh*sp*cr},
+		{-sh*cr + ch*sp*sr, ch * cp, -sh*sr - ch*sp*cr},
+		{-cp * sr, sp, cp * cr},
+	}
+}
+
+// IsUpwardLooking will check if the ADCP is looking up.
+// The roll of an upward looking ADCP is near 180 degrees.
+func (anc *AncillaryDataSet) IsUpwardLooking() bool {
+	return anc.Roll > 90 || anc.Roll < -90
+}
+
+// newVelocityBase will create the base dataset for a transformed velocity dataset.
+func newVelocityBase(base BaseDataSet, id string) BaseDataSet {
+	return BaseDataSet{
+		Enstype:           dataTypeFloat,
+		NumElements:       base.NumElements,
+		ElementMultiplier: numVelocityComponents,
+		Imag:              base.Imag,
+		NameLen:           defaultNameLength,
+		Name:              id + "\x00",
+		badToNaN:          base.badToNaN,
+	}
+}
+
+// invert3 will invert the 3x3 matrix.
+func invert3(m [3][3]float64) ([3][3]float64, error) {
+	var inv [3][3]float64
+
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+
+	if math.Abs(det) < 1e-12 {
+		return inv, errors.New("matrix can not be inverted")
+	}
+
+	inv[0][0] = (m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det
+	inv[0][1] = (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det
+	inv[0][2] = (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det
+	inv[1][0] = (m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det
+	inv[1][1] = (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det
+	inv[1][2] = (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det
+	inv[2][0] = (m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det
+	inv[2][1] = (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det
+	inv[2][2] = (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det
+
+	return inv, nil
+}
@@ -0,0 +1,317 @@
+package rti
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// retransformTolerance is the largest difference in m/s allowed between the
+// firmware velocities and the retransformed velocities.
+const retransformTolerance = 0.005
+
+// TestRetransformRecorded will decode the ensembles recorded by an ADCP in
+// testdata/*.ens, retransform the beam velocities and compare the result with
+// the Instrument and Earth velocities computed by the firmware.
+func TestRetransformRecorded(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.ens"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no recorded ensembles in testdata/*.ens")
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ensembles := splitEnsembles(data)
+		if len(ensembles) == 0 {
+			t.Fatalf("%s: no ensembles found", file)
+		}
+
+		for i, raw := range ensembles {
+			ens := decodeEnsemble(raw, &DecodeOptions{})
+			if ens.IsVerticalBeam() {
+				continue
+			}
+
+			inst := ens.GetInstrumentVelocityData().Velocity
+			earth := ens.GetEarthVelocityData().Velocity
+			if err := ens.Retransform(TransformOptions{}); err != nil {
+				t.Fatalf("%s ensemble %d: %v", file, i, err)
+			}
+
+			compareVelocities(t, file, i, "instrument", inst, ens.InstrumentVelocityData.Velocity)
+			compareVelocities(t, file, i, "earth", earth, ens.EarthVelocityData.Velocity)
+		}
+	}
+}
+
+// instrumentToBeam will compute the 4 beam velocities for the instrument velocities.
+// It is the inverse of the 4 beam matrix from BeamToInstrumentMatrix.
+func instrumentToBeam(x, y, z, e, beamAngle, beamOffset float64) []float32 {
+	// Rotate X and Y back by the beam offset
+	offset := beamOffset * (math.Pi / 180.0)
+	x, y = x*math.Cos(offset)+y*math.Sin(offset), -x*math.Sin(offset)+y*math.Cos(offset)
+
+	sin, cos := math.Sincos(beamAngle * (math.Pi / 180.0))
+	b01 := cos*z + sin*e/math.Sqrt2 // (B0 + B1) / 2
+	b23 := cos*z - sin*e/math.Sqrt2 // (B2 + B3) / 2
+
+	return []float32{float32(b01 + sin*x), float32(b01 - sin*x), float32(b23 - sin*y), float32(b23 + sin*y)}
+}
+
+// newBeamVelocity will create a Beam Velocity Data Set with a bin for each set of beams.
+func newBeamVelocity(bins ...[]float32) *BeamVelocityDataSet {
+	beam := &BeamVelocityDataSet{Velocity: NewBinBeamMatrix[float32](len(bins), len(bins[0]))}
+	beam.Base.NumElements = uint32(len(bins))
+	beam.Base.ElementMultiplier = uint32(len(bins[0]))
+	for bin, beams := range bins {
+		for b, v := range beams {
+			beam.Velocity.Set(bin, b, v)
+		}
+	}
+
+	return beam
+}
+
+// TestBeamToInstrumentRoundTrip will transform known beam velocities to instrument
+// velocities and back to the same beam velocities.
+func TestBeamToInstrumentRoundTrip(t *testing.T) {
+	tests := []struct {
+		beamAngle, beamOffset float64
+		beams                 []float32
+	}{
+		{20, 0, []float32{0.30, -0.10, 0.20, 0.05}},
+		{20, 45, []float32{0.30, -0.10, 0.20, 0.05}},
+		{30, 0, []float32{-1.25, 0.80, 0.10, -0.40}},
+		{15, 0, []float32{0.01, 0.02, 0.03, 0.04}},
+	}
+
+	for _, test := range tests {
+		matrix, err := BeamToInstrumentMatrix(test.beamAngle, 4, test.beamOffset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		inst := BeamToInstrument(newBeamVelocity(test.beams), matrix, false)
+		vel := inst.Velocity.Bin(0)
+		got := instrumentToBeam(float64(vel[InstrumentX]), float64(vel[InstrumentY]), float64(vel[InstrumentZ]), float64(vel[InstrumentError]), test.beamAngle, test.beamOffset)
+
+		for b := range test.beams {
+			if math.Abs(float64(got[b]-test.beams[b])) > 1e-5 {
+				t.Errorf("angle %g offset %g: beam %d = %g, want %g", test.beamAngle, test.beamOffset, b, got[b], test.beams[b])
+			}
+		}
+	}
+}
+
+// TestBeamToInstrumentKnown will check the 4 beam transform with hand computed values.
+// With a 30 degree beam angle sin = 0.5, so X = B0 - B1 and Y = B3 - B2.
+func TestBeamToInstrumentKnown(t *testing.T) {
+	matrix, err := BeamToInstrumentMatrix(30, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst := BeamToInstrument(newBeamVelocity([]float32{0.5, 0.1, 0.2, 0.4}), matrix, false)
+	z := 1.2 / (4 * math.Cos(30*math.Pi/180))
+	want := []float64{0.4, 0.2, z, 0}
+	for i, w := range want {
+		if got := inst.Velocity.At(0, i); math.Abs(float64(got)-w) > 1e-6 {
+			t.Errorf("velocity %d = %g, want %g", i, got, w)
+		}
+	}
+}
+
+// TestThreeBeamSolution will check a bad beam in a 4 beam system is computed from
+// the other 3 beams when the error velocity is 0.
+func TestThreeBeamSolution(t *testing.T) {
+	matrix, err := BeamToInstrumentMatrix(20, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, y, z := 0.4, -0.25, 0.05
+	for bad := 0; bad < 4; bad++ {
+		beams := instrumentToBeam(x, y, z, 0, 20, 0)
+		beams[bad] = BadVelocity
+		beam := newBeamVelocity(beams)
+
+		inst := BeamToInstrument(beam, matrix, true)
+		for i, w := range []float64{x, y, z, 0} {
+			if got := inst.Velocity.At(0, i); math.Abs(float64(got)-w) > 1e-5 {
+				t.Errorf("bad beam %d: velocity %d = %g, want %g", bad, i, got, w)
+			}
+		}
+
+		// Without the 3 beam solution the bin is bad
+		inst = BeamToInstrument(beam, matrix, false)
+		if !IsBad(inst.Velocity.At(0, InstrumentX)) {
+			t.Errorf("bad beam %d: X = %g without the 3 beam solution, want bad", bad, inst.Velocity.At(0, InstrumentX))
+		}
+	}
+
+	// 2 bad beams can not be solved
+	beams := instrumentToBeam(x, y, z, 0, 20, 0)
+	beams[0], beams[2] = BadVelocity, BadVelocity
+	inst := BeamToInstrument(newBeamVelocity(beams), matrix, true)
+	if !IsBad(inst.Velocity.At(0, InstrumentX)) {
+		t.Errorf("2 bad beams: X = %g, want bad", inst.Velocity.At(0, InstrumentX))
+	}
+}
+
+// TestThreeBeamMatrix will check the 3 beam system matrix with beams 120 degrees apart.
+func TestThreeBeamMatrix(t *testing.T) {
+	matrix, err := BeamToInstrumentMatrix(20, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, y, z := 0.3, 0.1, -0.2
+	sin, cos := math.Sincos(20 * math.Pi / 180)
+	beams := make([]float32, 3)
+	for b := range beams {
+		az := float64(b) * 2 * math.Pi / 3
+		beams[b] = float32(x*sin*math.Cos(az) + y*sin*math.Sin(az) + z*cos)
+	}
+
+	inst := BeamToInstrument(newBeamVelocity(beams), matrix, false)
+	for i, w := range []float64{x, y, z, 0} {
+		if got := inst.Velocity.At(0, i); math.Abs(float64(got)-w) > 1e-5 {
+			t.Errorf("velocity %d = %g, want %g", i, got, w)
+		}
+	}
+}
+
+// TestEarthRotation will check the rotation matrix with hand computed values.
+func TestEarthRotation(t *testing.T) {
+	s10 := math.Sin(10 * math.Pi / 180)
+	c10 := math.Cos(10 * math.Pi / 180)
+
+	tests := []struct {
+		heading, pitch, roll float64
+		want                 [3][3]float64
+	}{
+		// Level and pointing North
+		{0, 0, 0, [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}},
+		// Y points East and X points South
+		{90, 0, 0, [3][3]float64{{0, 1, 0}, {-1, 0, 0}, {0, 0, 1}}},
+		// Y points West and X points North
+		{270, 0, 0, [3][3]float64{{0, -1, 0}, {1, 0, 0}, {0, 0, 1}}},
+		// Pitch up 10 degrees
+		{0, 10, 0, [3][3]float64{{1, 0, 0}, {0, c10, -s10}, {0, s10, c10}}},
+		// Upward looking, the pitch is not flipped by the 180 degree roll
+		{0, 10, 180, [3][3]float64{{-1, 0, 0}, {0, c10, s10}, {0, s10, -c10}}},
+	}
+
+	for _, test := range tests {
+		got := earthRotation(test.heading, test.pitch, test.roll)
+		for i := range got {
+			for j := range got[i] {
+				if math.Abs(got[i][j]-test.want[i][j]) > 1e-9 {
+					t.Errorf("earthRotation(%g, %g, %g)[%d][%d] = %g, want %g", test.heading, test.pitch, test.roll, i, j, got[i][j], test.want[i][j])
+				}
+			}
+		}
+	}
+}
+
+// TestIsUpwardLooking will check the roll branch used for an upward looking ADCP.
+func TestIsUpwardLooking(t *testing.T) {
+	tests := []struct {
+		roll float32
+		want bool
+	}{
+		{0, false},
+		{15, false},
+		{-15, false},
+		{90, false},
+		{-90, false},
+		{91, true},
+		{178, true},
+		{180, true},
+		{-178, true},
+	}
+
+	for _, test := range tests {
+		anc := AncillaryDataSet{Roll: test.roll}
+		if got := anc.IsUpwardLooking(); got != test.want {
+			t.Errorf("IsUpwardLooking() with roll %g = %v, want %v", test.roll, got, test.want)
+		}
+	}
+}
+
+// compareVelocities will check the X, Y and Z or East, North and Vertical
+// velocities of the bins that are good in both matrices.
+func compareVelocities(t *testing.T, file string, index int, name string, want BinBeamMatrix[float32], got BinBeamMatrix[float32]) {
+	t.Helper()
+
+	if want.NumBins != got.NumBins {
+		t.Fatalf("%s ensemble %d: %s has %d bins, want %d", file, index, name, got.NumBins, want.NumBins)
+	}
+
+	for bin := 0; bin < want.NumBins; bin++ {
+		for beam := 0; beam < 3 && beam < want.NumBeams && beam < got.NumBeams; beam++ {
+			w, g := want.At(bin, beam), got.At(bin, beam)
+			if IsBad(w) || IsBad(g) {
+				continue
+			}
+
+			if math.Abs(float64(w-g)) > retransformTolerance {
+				t.Errorf("%s ensemble %d: %s bin %d beam %d = %g, want %g", file, index, name, bin, beam, g, w)
+			}
+		}
+	}
+}
+
+// splitEnsembles will split the recorded data into ensembles with a good checksum.
+func splitEnsembles(data []byte) [][]byte {
+	var ensembles [][]byte
+
+	for i := 0; i+hdlen <= len(data); {
+		if !isEnsembleID(data[i : i+idlen]) {
+			i++
+			continue
+		}
+
+		ensNum := binary.LittleEndian.Uint32(data[i+16 : i+20])
+		invEnsNum := binary.LittleEndian.Uint32(data[i+20 : i+24])
+		size := binary.LittleEndian.Uint32(data[i+24 : i+28])
+		invSize := binary.LittleEndian.Uint32(data[i+28 : i+32])
+		end := i + hdlen + int(size) + checksumSize
+		if ensNum != ^invEnsNum || size != ^invSize || end > len(data) {
+			i++
+			continue
+		}
+
+		ens := data[i:end]
+		checksum := binary.LittleEndian.Uint32(ens[len(ens)-checksumSize:])
+		if uint32(calculateEnsembleChecksum(ens)) != checksum {
+			i++
+			continue
+		}
+
+		ensembles = append(ensembles, ens)
+		i = end
+	}
+
+	return ensembles
+}
+
+// isEnsembleID will check if the bytes are the 16 byte ensemble ID.
+func isEnsembleID(id []byte) bool {
+	for _, b := range id {
+		if b != 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
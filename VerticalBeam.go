@@ -0,0 +1,181 @@
+package rti
+
+import "errors"
+
+// VerticalBeam is the fifth beam of the ADCP.  The vertical beam
+// is usually sent as a separate subsystem with a single beam.  The
+// bin data of the vertical beam is held here so it can be used with
+// or merged into a 4 beam ensemble.
+type VerticalBeam struct {
+	Subsystem     Subsystem // Subsystem code of the vertical beam
+	FirstBinRange float32   // First bin location in meters
+	BinSize       float32   // Bin size in meters
+	Velocity      []float32 // Velocity data in m/s for each bin
+	Amplitude     []float32 // Amplitude data in dB for each bin
+	Correlation   []float32 // Correlation data in % for each bin
+}
+
+// IsVerticalBeam will check if the ensemble is from a vertical beam subsystem.
+// The subsystem code in the firmware is checked first.  If the subsystem code
+// is not a vertical beam, the number of beams is checked.
+func (ens *Ensemble) IsVerticalBeam() bool {
+	ensData := ens.GetEnsembleData()
+	if Subsystem(ensData.Firmware.SubsystemCode).IsVertical() {
+		return true
+	}
+
+	return ensData.NumBeams == 1 || ens.BeamVelocityData.IsVerticalBeam()
+}
+
+// VerticalBeam will get the vertical beam for the ensemble.  If a vertical beam
+// ensemble was merged into this ensemble, the merged vertical beam is returned.
+// If this ensemble is a vertical beam ensemble, the vertical beam is created from
+// its datasets.  Otherwise nil is returned.
+func (ens *Ensemble) VerticalBeam() *VerticalBeam {
+	if ens.VerticalBeamData != nil {
+		return ens.VerticalBeamData
+	}
+
+	if !ens.IsVerticalBeam() {
+		return nil
+	}
+
+	return &VerticalBeam{
+		Subsystem:     Subsystem(ens.GetEnsembleData().Firmware.SubsystemCode),
+		FirstBinRange: ens.GetAncillaryData().FirstBinRange,
+		BinSize:       ens.GetAncillaryData().BinSize,
+		Velocity:      firstBeam(ens.GetBeamVelocityData().Velocity),
+		Amplitude:     firstBeam(ens.GetAmplitudeData().Amplitude),
+		Correlation:   firstBeam(ens.GetCorrelationData().Correlation),
+	}
+}
+
+// MergeVerticalBeam will merge the vertical beam ensemble into this 4 beam ensemble.
+// Both ensembles must have the same ensemble time to be a single sample.
+func (ens *Ensemble) MergeVerticalBeam(vert *Ensemble) error {
+	if ens.IsVerticalBeam() {
+		return errors.New("ensemble to merge into is a vertical beam ensemble")
+	}
+
+	if !vert.IsVerticalBeam() {
+		return errors.New("ensemble to merge is not a vertical beam ensemble")
+	}
+
+	if !sameEnsembleTime(ens.GetEnsembleData(), vert.GetEnsembleData()) {
+		return errors.New("vertical beam ensemble time does not match the ensemble time")
+	}
+
+	ens.VerticalBeamData = vert.VerticalBeam()
+
+	return nil
+}
+
+// MergeVerticalBeams will merge all the vertical beam ensembles into the 4 beam
+// ensembles with the same ensemble time.  Vertical beam ensembles that do not
+// have a matching 4 beam ensemble are kept as separate ensembles in the same
+// place as the input, so the ensembles stay in time order.
+func MergeVerticalBeams(ensembles []Ensemble) []Ensemble {
+	merged := make([]Ensemble, len(ensembles))
+	copy(merged, ensembles)
+
+	// 4 beam ensembles waiting for a vertical beam by ensemble time
+	waiting := make(map[ensembleTime][]int)
+	for i := range merged {
+		if merged[i].IsVerticalBeam() || merged[i].VerticalBeamData != nil {
+			continue
+		}
+
+		key := timeOf(merged[i].GetEnsembleData())
+		waiting[key] = append(waiting[key], i)
+	}
+
+	// Flag the vertical beam ensembles merged into a 4 beam ensemble
+	used := make([]bool, len(merged))
+	for v := range merged {
+		if !merged[v].IsVerticalBeam() {
+			continue
+		}
+
+		key := timeOf(merged[v].GetEnsembleData())
+		candidates := waiting[key]
+		if len(candidates) == 0 {
+			continue
+		}
+
+		if merged[candidates[0]].MergeVerticalBeam(&merged[v]) == nil {
+			used[v] = true
+			waiting[key] = candidates[1:]
+		}
+	}
+
+	result := merged[:0]
+	for i := range merged {
+		if !used[i] {
+			result = append(result, merged[i])
+		}
+	}
+
+	return result
+}
+
+// NumBins will get the number of bins in the vertical beam.
+func (vb *VerticalBeam) NumBins() int {
+	return len(vb.Velocity)
+}
+
+// VelocityAt will get the velocity for the bin.
+// If the bin does not exist, BadVelocity is returned.
+func (vb *VerticalBeam) VelocityAt(bin int) float32 {
+	return binValue(vb.Velocity, bin)
+}
+
+// AmplitudeAt will get the amplitude for the bin.
+// If the bin does not exist, BadVelocity is returned.
+func (vb *VerticalBeam) AmplitudeAt(bin int) float32 {
+	return binValue(vb.Amplitude, bin)
+}
+
+// CorrelationAt will get the correlation for the bin.
+// If the bin does not exist, BadVelocity is returned.
+func (vb *VerticalBeam) CorrelationAt(bin int) float32 {
+	return binValue(vb.Correlation, bin)
+}
+
+// BinRange will get the range to the center of the bin in meters.
+func (vb *VerticalBeam) BinRange(bin int) float32 {
+	return vb.FirstBinRange + float32(bin)*vb.BinSize
+}
+
+// binValue will get the value for the bin or BadVelocity
+// if the bin is out of range.
+func binValue(data []float32, bin int) float32 {
+	if bin < 0 || bin >= len(data) {
+		return BadVelocity
+	}
+
+	return data[bin]
+}
+
+// firstBeam will get the first beam of each bin.
+func firstBeam(data BinBeamMatrix[float32]) []float32 {
+	if data.NumBeams == 0 {
+		return nil
+	}
+
+	return data.Beam(0)
+}
+
+// ensembleTime is the date and time of an ensemble used to match ensembles.
+type ensembleTime struct {
+	year, month, day, hour, minute, second, hsec uint32
+}
+
+// timeOf will get the date and time of the ensemble.
+func timeOf(ens *EnsembleDataSet) ensembleTime {
+	return ensembleTime{ens.Year, ens.Month, ens.Day, ens.Hour, ens.Minute, ens.Second, ens.HSec}
+}
+
+// sameEnsembleTime will check if both ensembles have the same date and time.
+func sameEnsembleTime(a *EnsembleDataSet, b *EnsembleDataSet) bool {
+	return timeOf(a) == timeOf(b)
+}